@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/audit"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/supplementer"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ExitOK int = iota
+	ExitErr
+)
+
+func main() {
+	err := _main()
+	if err != nil {
+		log.Println(fmt.Errorf("error from _main: %w", err))
+		os.Exit(ExitErr)
+	}
+
+	os.Exit(ExitOK)
+}
+
+// coverage is the Bookcision file which matches a source, and the number of placeholders of the
+// source that it would fill.
+type coverage struct {
+	file   string
+	filled int
+}
+
+func _main() error {
+	var inputFilePath, inputFormat, supplementDirPath, aliasesFilePath string
+	var locationTolerance int
+	var verbose, all bool
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML, JSON or JSON Lines file that is output by the cmd/parse command in this project, without the -remove-clipping-limit flag. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
+	flag.StringVar(&supplementDirPath, "supplement-dir-path", "", "Directory with JSON files exported using Bookcision. If set, the report shows whether each source is covered by one of these files.")
+	flag.IntVar(&locationTolerance, "location-tolerance", 2, "Largest difference between the locations of a Kindle highlight and a Bookcision highlight for them to be matched")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
+	flag.BoolVar(&all, "all", false, "Include sources which do not have any clipping limit placeholders")
+	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	flag.Parse()
+
+	if inputFilePath == "" {
+		flag.PrintDefaults()
+		return errors.New("input file path must be non-empty")
+	}
+
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
+		return fmt.Errorf("input file must point to a valid file > %w", err)
+	}
+
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
+	logger, err := zap.NewProduction()
+	if verbose {
+		logger, err = zap.NewDevelopment()
+	}
+	if err != nil {
+		return fmt.Errorf("could not create logger > %w", err)
+	}
+
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read source aliases > %w", err)
+	}
+
+	logger.Info("reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
+
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
+	if err != nil {
+		return fmt.Errorf("could not read parsed clippings > %w", err)
+	}
+
+	clippings = aliases.Apply(clippings)
+
+	logger.Info("read clippings", zap.Int("clipping_count", len(clippings)))
+
+	limits := audit.ClippingLimits(clippings)
+
+	coverages := make(map[string]coverage)
+	if supplementDirPath != "" {
+		sources := make([]string, 0, len(limits))
+		for _, limit := range limits {
+			sources = append(sources, limit.Source)
+		}
+
+		supplementFilePaths, err := filepath.Glob(filepath.Join(supplementDirPath, "*.json"))
+		if err != nil {
+			return fmt.Errorf("could not list JSON files in supplement directory > %w", err)
+		}
+		sort.Strings(supplementFilePaths)
+
+		for _, filePath := range supplementFilePaths {
+			bookcisionParser := parser.BookcisionClippings{
+				FilePath: filePath,
+				Logger:   logger.With(zap.String("component", "parser"), zap.String("subcomponent", "bookcision")),
+			}
+
+			set, bookcisionClippings, err := bookcisionParser.ParseSet()
+			if err != nil {
+				return fmt.Errorf("could not parse bookcision JSON file '%s' > %w", filePath, err)
+			}
+
+			source, err := supplementer.MatchSource(sources, set.Title, set.Authors)
+			if err != nil {
+				logger.Warn("skipping bookcision JSON file", zap.String("file", filePath), zap.Error(err))
+				continue
+			}
+
+			bookcision := supplementer.Bookcision{
+				SourceRegex:       regexp.MustCompile("^" + regexp.QuoteMeta(source) + "$"),
+				LocationTolerance: locationTolerance,
+				Logger:            logger.With(zap.String("component", "supplementer"), zap.String("file", filePath)),
+			}
+
+			_, report, err := bookcision.MergeWithReport(utils.FilterBySource(clippings, source), aliases.Apply(bookcisionClippings))
+			if err != nil {
+				return fmt.Errorf("could not supplement kindle with bookcision JSON file '%s' > %w", filePath, err)
+			}
+
+			coverages[source] = coverage{
+				file:   filepath.Base(filePath),
+				filled: len(report.Filled),
+			}
+		}
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "SOURCE\tHIGHLIGHTS\tPLACEHOLDERS\tTRUNCATED AT\tTRUNCATED ON\tBOOKCISION\tCOVERED")
+	for _, limit := range limits {
+		if limit.Placeholders == 0 && !all {
+			continue
+		}
+
+		truncatedAt, truncatedOn := "-", "-"
+		if limit.Placeholders > 0 {
+			truncatedAt = fmt.Sprintf("%d", limit.TruncatedAt)
+			truncatedOn = limit.TruncatedOn.Format("2006-01-02")
+		}
+
+		// Sources without placeholders do not need Bookcision.
+		file, covered := "-", limit.Placeholders == 0
+		if c, ok := coverages[limit.Source]; ok {
+			file = c.file
+			covered = c.filled == limit.Placeholders
+		}
+
+		fmt.Fprintf(table, "%s\t%d\t%d\t%s\t%s\t%s\t%t\n",
+			limit.Source, limit.Highlights, limit.Placeholders, truncatedAt, truncatedOn, file, covered)
+	}
+
+	if err := table.Flush(); err != nil {
+		return fmt.Errorf("could not write report > %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/compact"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"go.uber.org/zap"
+)
+
+const (
+	ExitOK int = iota
+	ExitErr
+)
+
+func main() {
+	err := _main()
+	if err != nil {
+		log.Println(fmt.Errorf("error from _main: %w", err))
+		os.Exit(ExitErr)
+	}
+
+	os.Exit(ExitOK)
+}
+
+func _main() error {
+	var inputFilePath, archiveFilePath, outputFilePath, finishedSourceFilter, aliasesFilePath string
+	var keepMonths int
+	var verbose, removeClippingLimit bool
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. The My Clippings.txt file on the Kindle")
+	flag.StringVar(&archiveFilePath, "archive-file-path", "", "Archive file. A full copy of the input file is written here before the input file is trimmed.")
+	flag.StringVar(&outputFilePath, "output-file-path", "", "Output file. The trimmed file is written here. If empty, the input file is replaced with the trimmed file.")
+	flag.IntVar(&keepMonths, "keep-months", 0, "Remove clippings which were created more than this many months ago. If 0, clippings are not removed by their age.")
+	flag.StringVar(&finishedSourceFilter, "finished-source-filter", "", "Regular expression for the sources of finished books. Their clippings are removed.")
+	flag.BoolVar(&removeClippingLimit, "remove-clipping-limit", false, "Remove clippings which indicate that the clipping text was not saved to the text file")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
+	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	flag.Parse()
+
+	if inputFilePath == "" {
+		flag.PrintDefaults()
+		return errors.New("input file path must be non-empty")
+	}
+
+	if _, err := os.Stat(inputFilePath); err != nil {
+		return fmt.Errorf("input file must point to a valid file > %w", err)
+	}
+
+	if archiveFilePath == "" {
+		flag.PrintDefaults()
+		return errors.New("archive file path must be non-empty")
+	}
+
+	if _, err := os.Stat(archiveFilePath); err == nil {
+		return errors.New("archive file path must not exist before this script runs")
+	}
+
+	if outputFilePath == "" {
+		outputFilePath = inputFilePath
+	}
+
+	if keepMonths < 0 {
+		flag.PrintDefaults()
+		return fmt.Errorf("keep months must not be negative, not %d", keepMonths)
+	}
+
+	rules := compact.Rules{
+		RemoveClippingLimit: removeClippingLimit,
+	}
+	if keepMonths > 0 {
+		rules.Since = time.Now().AddDate(0, -keepMonths, 0)
+	}
+	if finishedSourceFilter != "" {
+		fsRx, err := regexp.Compile(finishedSourceFilter)
+		if err != nil {
+			return fmt.Errorf("supplied finished source filter '%s' is an invalid regular expression > %w", finishedSourceFilter, err)
+		}
+		rules.Finished = fsRx
+	}
+
+	logger, err := zap.NewProduction()
+	if verbose {
+		logger, err = zap.NewDevelopment()
+	}
+	if err != nil {
+		return fmt.Errorf("could not create logger > %w", err)
+	}
+
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read source aliases > %w", err)
+	}
+
+	readSections := func(filePath string) ([]parser.KindleSection, error) {
+		return parser.NewKindleClippingsWithLogger(filePath, aliases, logger.With(zap.String("component", "processor"))).Sections()
+	}
+
+	content, err := os.ReadFile(inputFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read input file > %w", err)
+	}
+
+	original, err := readSections(inputFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read sections from input file > %w", err)
+	}
+
+	kept, removed := rules.Split(original)
+
+	logger.Info("split sections", zap.Int("section_count", len(original)), zap.Int("kept_count", len(kept)), zap.Int("removed_count", len(removed)))
+
+	if err := writeFile(archiveFilePath, content); err != nil {
+		return fmt.Errorf("could not write archive file > %w", err)
+	}
+
+	archive, err := readSections(archiveFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read sections from archive file > %w", err)
+	}
+
+	logger.Info("wrote archive file", zap.String("file", archiveFilePath), zap.Int("section_count", len(archive)))
+
+	// The trimmed file is written next to the output file and checked before it replaces the output
+	// file, so that the file on the device is never left incomplete.
+	trimmedFile, err := os.CreateTemp(filepath.Dir(outputFilePath), ".compact-*.txt")
+	if err != nil {
+		return fmt.Errorf("could not create trimmed file > %w", err)
+	}
+	trimmedFilePath := trimmedFile.Name()
+	defer os.Remove(trimmedFilePath)
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(outputFilePath); err == nil {
+		mode = info.Mode()
+	}
+	if err := trimmedFile.Chmod(mode); err != nil {
+		trimmedFile.Close()
+		return fmt.Errorf("could not set the mode of trimmed file > %w", err)
+	}
+
+	if err := compact.Write(trimmedFile, compact.DetectLayout(content), kept); err != nil {
+		trimmedFile.Close()
+		return fmt.Errorf("could not write trimmed file > %w", err)
+	}
+	if err := trimmedFile.Sync(); err != nil {
+		trimmedFile.Close()
+		return fmt.Errorf("could not write trimmed file > %w", err)
+	}
+	if err := trimmedFile.Close(); err != nil {
+		return fmt.Errorf("could not write trimmed file > %w", err)
+	}
+
+	trimmed, err := readSections(trimmedFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read sections from trimmed file > %w", err)
+	}
+
+	if len(trimmed) != len(kept) {
+		return fmt.Errorf("refusing to replace the output file: trimmed file has %d sections instead of %d", len(trimmed), len(kept))
+	}
+
+	if err := compact.Verify(original, archive, trimmed); err != nil {
+		return fmt.Errorf("refusing to replace the output file > %w", err)
+	}
+
+	if err := os.Rename(trimmedFilePath, outputFilePath); err != nil {
+		return fmt.Errorf("could not replace the output file with the trimmed file > %w", err)
+	}
+
+	logger.Info("wrote trimmed file", zap.String("file", outputFilePath), zap.Int("section_count", len(trimmed)))
+
+	return nil
+}
+
+// writeFile creates the file, which must not exist, and writes the content to the disk before it
+// returns.
+func writeFile(filePath string, content []byte) error {
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/duplicates"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ExitOK int = iota
+	ExitErr
+)
+
+func main() {
+	err := _main()
+	if err != nil {
+		log.Println(fmt.Errorf("error from _main: %w", err))
+		os.Exit(ExitErr)
+	}
+
+	os.Exit(ExitOK)
+}
+
+// Report is written to stdout. It has the same structure as the aliases file, so it can be
+// reviewed, edited and then used as the aliases file for this and all other commands.
+type Report struct {
+	Aliases parser.SourceAliases `yaml:"aliases"`
+	Pairs   []ReportPair         `yaml:"pairs"`
+}
+
+type ReportPair struct {
+	Similarity float64        `yaml:"similarity"`
+	First      ReportClipping `yaml:"first"`
+	Second     ReportClipping `yaml:"second"`
+}
+
+type ReportClipping struct {
+	Source   string `yaml:"source"`
+	Location int    `yaml:"location"`
+	Text     string `yaml:"text"`
+}
+
+func _main() error {
+	var inputFilePath, inputFormat, outputFilePath, outputFormat, aliasesFilePath string
+	var similarityThreshold float64
+	var shingleSize, minPairs int
+	var verbose bool
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML, JSON or JSON Lines file that is output by the cmd/parse command in this project. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
+	flag.StringVar(&outputFilePath, "output-file-path", "", "Output file. If set, the proposed aliases are merged into their canonical source and the output is written in the format of -output-format.")
+	flag.StringVar(&outputFormat, "output-format", "", "Format of the output file: "+clippingsio.FormatFlagUsage)
+	flag.Float64Var(&similarityThreshold, "similarity-threshold", 0.8, "Minimum similarity (between 0 and 1) for two highlights to be considered duplicates")
+	flag.IntVar(&shingleSize, "shingle-size", 3, "Number of words in each shingle used to compare highlights")
+	flag.IntVar(&minPairs, "min-pairs", 3, "Minimum number of duplicate highlights two sources must share before they are proposed as aliases")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
+	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	flag.Parse()
+
+	if inputFilePath == "" {
+		flag.PrintDefaults()
+		return errors.New("input file path must be non-empty")
+	}
+
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
+		return fmt.Errorf("input file must point to a valid file > %w", err)
+	}
+
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
+	if outputFilePath != "" {
+		if err := clippingsio.CheckOutput(outputFilePath); err != nil {
+			return fmt.Errorf("output file path must not exist before this script runs > %w", err)
+		}
+	}
+
+	outputFileFormat, err := clippingsio.DetectFormat(outputFilePath, outputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid output format > %w", err)
+	}
+
+	if similarityThreshold <= 0 || similarityThreshold > 1 {
+		return fmt.Errorf("similarity threshold must be in the range (0, 1], got %f", similarityThreshold)
+	}
+
+	logger, err := zap.NewProduction()
+	if verbose {
+		logger, err = zap.NewDevelopment()
+	}
+	if err != nil {
+		return fmt.Errorf("could not create logger > %w", err)
+	}
+
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read source aliases > %w", err)
+	}
+
+	logger.Info("Reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
+
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
+	if err != nil {
+		return fmt.Errorf("could not read parsed clippings > %w", err)
+	}
+
+	clippings = aliases.Apply(clippings)
+
+	logger.Info("read clippings", zap.Int("clipping_count", len(clippings)))
+
+	detector := duplicates.CrossSource{
+		Logger:      logger.With(zap.String("component", "cross_source")),
+		Threshold:   similarityThreshold,
+		ShingleSize: shingleSize,
+	}
+
+	pairs := detector.Pairs(clippings)
+
+	proposedAliases := duplicates.ProposeAliases(clippings, pairs, minPairs)
+
+	logger.Info("found cross source duplicates", zap.Int("pair_count", len(pairs)), zap.Int("alias_count", len(proposedAliases)))
+
+	report := Report{
+		Aliases: proposedAliases,
+		Pairs:   make([]ReportPair, 0, len(pairs)),
+	}
+	for _, pair := range pairs {
+		report.Pairs = append(report.Pairs, ReportPair{
+			Similarity: pair.Similarity,
+			First: ReportClipping{
+				Source:   pair.First.Source,
+				Location: pair.First.LocationInSource.Start,
+				Text:     pair.First.Text,
+			},
+			Second: ReportClipping{
+				Source:   pair.Second.Source,
+				Location: pair.Second.LocationInSource.Start,
+				Text:     pair.Second.Text,
+			},
+		})
+	}
+
+	// The report is written to the standard error if the merged clippings are written to the standard
+	// output, so that it does not end up in the middle of them.
+	var reportOutput io.Writer = os.Stdout
+	if outputFilePath == clippingsio.Stdio {
+		reportOutput = os.Stderr
+	}
+
+	reportWriter := yaml.NewEncoder(reportOutput)
+	defer reportWriter.Close()
+	if err := reportWriter.Encode(report); err != nil {
+		return fmt.Errorf("could not encode cross source duplicates report into YAML > %w", err)
+	}
+
+	if outputFilePath == "" {
+		return nil
+	}
+
+	mergedClippings := proposedAliases.Apply(clippings)
+
+	sort.Sort(mergedClippings)
+
+	if err := clippingsio.WriteFile(outputFilePath, outputFileFormat, mergedClippings); err != nil {
+		return fmt.Errorf("could not write merged clippings > %w", err)
+	}
+
+	return nil
+}
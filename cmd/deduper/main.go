@@ -7,11 +7,10 @@ import (
 	"os"
 	"sort"
 
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/duplicates"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
 	"go.uber.org/zap"
-
-	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -30,10 +29,13 @@ func main() {
 }
 
 func _main() error {
-	var inputFilePath, outputFilePath string
+	var inputFilePath, inputFormat, outputFilePath, outputFormat, aliasesFilePath string
 	var verbose bool
-	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML file that is output by the cmd/parse command in this project.")
-	flag.StringVar(&outputFilePath, "output-file-path", "", "Output file. Output will be written in the YAML format.")
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML, JSON or JSON Lines file that is output by the cmd/parse command in this project. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
+	flag.StringVar(&outputFilePath, "output-file-path", "", "Output file, or - for the standard output. Output will be written in the format of -output-format.")
+	flag.StringVar(&outputFormat, "output-format", "", "Format of the output file: "+clippingsio.FormatFlagUsage)
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
 	flag.Parse()
 
@@ -41,16 +43,28 @@ func _main() error {
 		return errors.New("input file path must be non-empty")
 	}
 
-	if _, err := os.Stat(inputFilePath); err != nil {
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
 		return fmt.Errorf("input file must point to a valid file > %w", err)
 	}
 
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
 	if outputFilePath == "" {
 		return errors.New("output file path must be non-empty")
 	}
 
-	if _, err := os.Stat(outputFilePath); err == nil {
-		return errors.New("output file path must not exist before this script runs")
+	if err := clippingsio.CheckOutput(outputFilePath); err != nil {
+		return fmt.Errorf("output file path must not exist before this script runs > %w", err)
+	}
+
+	outputFileFormat, err := clippingsio.DetectFormat(outputFilePath, outputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid output format > %w", err)
 	}
 
 	logger, err := zap.NewProduction()
@@ -61,21 +75,21 @@ func _main() error {
 		return fmt.Errorf("could not create logger > %w", err)
 	}
 
-	logger.Info("Reading clippings from YAML file", zap.String("file", inputFilePath))
-
-	inputFile, err := os.Open(inputFilePath)
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
 	if err != nil {
-		return fmt.Errorf("could not create output yaml file > %w", err)
+		return fmt.Errorf("could not read source aliases > %w", err)
 	}
-	defer inputFile.Close()
 
-	reader := yaml.NewDecoder(inputFile)
-	var clippings parser.Clippings
-	if err := reader.Decode(&clippings); err != nil {
-		return fmt.Errorf("could not encode parsed clippings into YAML > %w", err)
+	logger.Info("Reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
+
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
+	if err != nil {
+		return fmt.Errorf("could not read parsed clippings > %w", err)
 	}
 
-	logger.Info("read clippings from parsed YAML file", zap.Int("clipping_count", len(clippings)))
+	clippings = aliases.Apply(clippings)
+
+	logger.Info("read clippings from parsed file", zap.Int("clipping_count", len(clippings)))
 
 	deduper := duplicates.RetainLatest{
 		Logger: logger.With(zap.String("component", "deduper")),
@@ -90,16 +104,8 @@ func _main() error {
 
 	sort.Sort(dedupedClippings)
 
-	outputFile, err := os.Create(outputFilePath)
-	if err != nil {
-		return fmt.Errorf("could not create output yaml file > %w", err)
-	}
-	defer outputFile.Close()
-
-	writer := yaml.NewEncoder(outputFile)
-	defer writer.Close()
-	if err := writer.Encode(dedupedClippings); err != nil {
-		return fmt.Errorf("could not encode parsed clippings into YAML > %w", err)
+	if err := clippingsio.WriteFile(outputFilePath, outputFileFormat, dedupedClippings); err != nil {
+		return fmt.Errorf("could not write deduplicated clippings > %w", err)
 	}
 
 	return nil
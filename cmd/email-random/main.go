@@ -8,12 +8,12 @@ import (
 	"math/big"
 	"os"
 
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/env"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/notifier"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
 	"go.uber.org/zap"
-	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -32,11 +32,12 @@ func main() {
 }
 
 func _main() error {
-	var inputFilePath string
+	var inputFilePath, inputFormat string
 	var verbose bool
 	var version bool
 	flag.BoolVar(&version, "version", false, "Print the build version")
-	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML file that is output by the cmd/parse command in this project.")
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML, JSON or JSON Lines file that is output by the cmd/parse command in this project. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
 	flag.Parse()
 
@@ -49,10 +50,16 @@ func _main() error {
 		return errors.New("input file path must be non-empty")
 	}
 
-	if _, err := os.Stat(inputFilePath); err != nil {
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
 		return fmt.Errorf("input file must point to a valid file > %w", err)
 	}
 
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
 	logger, err := zap.NewProduction()
 	if verbose {
 		logger, err = zap.NewDevelopment()
@@ -61,23 +68,16 @@ func _main() error {
 		return fmt.Errorf("could not create logger > %w", err)
 	}
 
-	logger.Info("Reading clippings from YAML file", zap.String("file", inputFilePath))
+	logger.Info("Reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
 
 	config, err := env.Process()
 	if err != nil {
 		return err
 	}
 
-	inputFile, err := os.Open(inputFilePath)
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
 	if err != nil {
-		return fmt.Errorf("could not create output yaml file > %w", err)
-	}
-	defer inputFile.Close()
-
-	reader := yaml.NewDecoder(inputFile)
-	var clippings parser.Clippings
-	if err := reader.Decode(&clippings); err != nil {
-		return fmt.Errorf("could not encode parsed clippings into YAML > %w", err)
+		return fmt.Errorf("could not read parsed clippings > %w", err)
 	}
 
 	logger.Info("read clippings", zap.Int("clipping_count", len(clippings)))
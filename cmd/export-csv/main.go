@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/export"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/library"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ExitOK int = iota
+	ExitErr
+)
+
+func main() {
+	err := _main()
+	if err != nil {
+		log.Println(fmt.Errorf("error from _main: %w", err))
+		os.Exit(ExitErr)
+	}
+
+	os.Exit(ExitOK)
+}
+
+func _main() error {
+	var inputFilePath, inputFormat, outputFilePath, columns, sourceFilter, aliasesFilePath, markupFilePath string
+	var verbose bool
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML, JSON or JSON Lines file that is output by the cmd/parse command in this project. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
+	flag.StringVar(&outputFilePath, "output-file-path", "", "Output file. If empty or -, the output is written to the standard output.")
+	flag.StringVar(&columns, "columns", "", "Comma-separated list of columns, e.g. title,page,text,note. If empty, the columns are the ones which Readwise imports. Columns must be from: "+strings.Join(export.CSVColumns(), ", "))
+	flag.StringVar(&sourceFilter, "source-filter", "", "Regular expression for filtering the source of clippings. If empty, all sources are exported.")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
+	flag.StringVar(&markupFilePath, "markup-file-path", "", "YAML file which maps tags to their roles. If empty, the tags described in the README are used.")
+	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	flag.Parse()
+
+	if inputFilePath == "" {
+		flag.PrintDefaults()
+		return errors.New("input file path must be non-empty")
+	}
+
+	if outputFilePath != "" {
+		if err := clippingsio.CheckOutput(outputFilePath); err != nil {
+			return fmt.Errorf("output file path must not exist before this script runs > %w", err)
+		}
+	}
+
+	var columnNames []string
+	if columns != "" {
+		known := make(map[string]bool)
+		for _, name := range export.CSVColumns() {
+			known[name] = true
+		}
+		for _, name := range strings.Split(columns, ",") {
+			name = strings.TrimSpace(name)
+			if !known[name] {
+				flag.PrintDefaults()
+				return fmt.Errorf("unknown column '%s'", name)
+			}
+			columnNames = append(columnNames, name)
+		}
+	}
+
+	var sourceFilterRx *regexp.Regexp
+	if sourceFilter != "" {
+		sfRx, err := regexp.Compile(sourceFilter)
+		if err != nil {
+			return fmt.Errorf("supplied source filter '%s' is an invalid regular expression > %w", sourceFilter, err)
+		}
+		sourceFilterRx = sfRx
+	}
+
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
+		return fmt.Errorf("input file must point to a valid file > %w", err)
+	}
+
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
+	logger, err := zap.NewProduction()
+	if verbose {
+		logger, err = zap.NewDevelopment()
+	}
+	if err != nil {
+		return fmt.Errorf("could not create logger > %w", err)
+	}
+
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read source aliases > %w", err)
+	}
+
+	vocabulary, err := markup.ReadMarkup(markupFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read markup > %w", err)
+	}
+
+	logger.Info("reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
+
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
+	if err != nil {
+		return fmt.Errorf("could not read parsed clippings > %w", err)
+	}
+
+	clippings = aliases.Apply(clippings)
+
+	logger.Info("read clippings", zap.Int("clipping_count", len(clippings)))
+
+	if sourceFilterRx != nil {
+		clippings = utils.FilterBySourceRegex(clippings, sourceFilterRx.Copy())
+	}
+
+	var output io.Writer = os.Stdout
+	if outputFilePath != "" && outputFilePath != clippingsio.Stdio {
+		outputFile, err := os.Create(outputFilePath)
+		if err != nil {
+			return fmt.Errorf("could not create output file > %w", err)
+		}
+		defer outputFile.Close()
+		output = outputFile
+	}
+
+	exporter := export.CSV{
+		Columns: columnNames,
+		Markup:  vocabulary,
+		Logger:  logger.With(zap.String("component", "export"), zap.String("format", "csv")),
+	}
+
+	books := library.Books(clippings, vocabulary)
+	if err := exporter.Write(output, books); err != nil {
+		return fmt.Errorf("could not export clippings to CSV > %w", err)
+	}
+
+	logger.Info("exported clippings", zap.Int("book_count", len(books)))
+
+	return nil
+}
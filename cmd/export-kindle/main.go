@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ExitOK int = iota
+	ExitErr
+)
+
+func main() {
+	err := _main()
+	if err != nil {
+		log.Println(fmt.Errorf("error from _main: %w", err))
+		os.Exit(ExitErr)
+	}
+
+	os.Exit(ExitOK)
+}
+
+func _main() error {
+	var inputFilePath, inputFormat, outputFilePath, variantName, sourceFilter, aliasesFilePath string
+	var verbose, crlf, byteOrderMark, leadingSeparator bool
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML, JSON or JSON Lines file that is output by the cmd/parse command in this project. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
+	flag.StringVar(&outputFilePath, "output-file-path", "", "Output file, or - for the standard output. Output will be in the format of Kindle's My Clippings.txt file.")
+	flag.StringVar(&variantName, "variant", "auto", "Format of the description lines: "+strings.Join(parser.VariantNames(), ", ")+". auto writes each clipping in the format that it was read in.")
+	flag.BoolVar(&crlf, "crlf", true, "End lines with CRLF, as Kindle does")
+	flag.BoolVar(&byteOrderMark, "byte-order-mark", true, "Start the file with a byte order mark, as Kindle does")
+	flag.BoolVar(&leadingSeparator, "leading-separator", false, "Start the file with a separator line")
+	flag.StringVar(&sourceFilter, "source-filter", "", "Regular expression for filtering the source of clippings. If empty, all sources are exported.")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
+	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	flag.Parse()
+
+	if inputFilePath == "" {
+		flag.PrintDefaults()
+		return errors.New("input file path must be non-empty")
+	}
+
+	if outputFilePath == "" {
+		flag.PrintDefaults()
+		return errors.New("output file path must be non-empty")
+	}
+
+	if err := clippingsio.CheckOutput(outputFilePath); err != nil {
+		return fmt.Errorf("output file path must not exist before this script runs > %w", err)
+	}
+
+	variant, err := parser.ParseVariant(variantName)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid variant > %w", err)
+	}
+
+	var sourceFilterRx *regexp.Regexp
+	if sourceFilter != "" {
+		sfRx, err := regexp.Compile(sourceFilter)
+		if err != nil {
+			return fmt.Errorf("supplied source filter '%s' is an invalid regular expression > %w", sourceFilter, err)
+		}
+		sourceFilterRx = sfRx
+	}
+
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
+		return fmt.Errorf("input file must point to a valid file > %w", err)
+	}
+
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
+	logger, err := zap.NewProduction()
+	if verbose {
+		logger, err = zap.NewDevelopment()
+	}
+	if err != nil {
+		return fmt.Errorf("could not create logger > %w", err)
+	}
+
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read source aliases > %w", err)
+	}
+
+	logger.Info("reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
+
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
+	if err != nil {
+		return fmt.Errorf("could not read parsed clippings > %w", err)
+	}
+
+	clippings = aliases.Apply(clippings)
+
+	logger.Info("read clippings", zap.Int("clipping_count", len(clippings)))
+
+	if sourceFilterRx != nil {
+		clippings = utils.FilterBySourceRegex(clippings, sourceFilterRx.Copy())
+	}
+
+	// The other commands sort clippings by their source. Kindle adds clippings at the end of the
+	// file, so sorting them by their create time gives the order of the file on the device.
+	sort.SliceStable(clippings, func(i, j int) bool {
+		return clippings[i].CreateTime.Before(clippings[j].CreateTime)
+	})
+
+	var output io.Writer = os.Stdout
+	if outputFilePath != clippingsio.Stdio {
+		outputFile, err := os.Create(outputFilePath)
+		if err != nil {
+			return fmt.Errorf("could not create output file > %w", err)
+		}
+		defer outputFile.Close()
+		output = outputFile
+	}
+
+	writer := parser.KindleWriter{
+		Variant:          variant,
+		CRLF:             crlf,
+		ByteOrderMark:    byteOrderMark,
+		LeadingSeparator: leadingSeparator,
+	}
+	if err := writer.Write(output, clippings); err != nil {
+		return fmt.Errorf("could not write clippings to the Kindle format > %w", err)
+	}
+
+	logger.Info("wrote clippings", zap.String("file", outputFilePath), zap.Int("clipping_count", len(clippings)))
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/library"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/templates"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ExitOK int = iota
+	ExitErr
+)
+
+// defaultTemplates are used when they are not replaced with the -template and -template-dir flags.
+//
+//go:embed library.org.tmpl properties.org.tmpl
+var defaultTemplates embed.FS
+
+func main() {
+	err := _main()
+	if err != nil {
+		log.Println(fmt.Errorf("error from _main: %w", err))
+		os.Exit(ExitErr)
+	}
+
+	os.Exit(ExitOK)
+}
+
+// TemplateData is the data of the library.org.tmpl template. properties.org.tmpl is executed with a
+// single clipping, and writes the property drawer of its heading.
+type TemplateData struct {
+	// Books are sorted by source. The entries of each book are its highlights, with the notes which
+	// are attached to them, and the notes which are not attached to any highlight.
+	Books []library.Book
+}
+
+func _main() error {
+	var inputFilePath, inputFormat, outputFilePath, sourceFilter, aliasesFilePath, markupFilePath, templatePath, templateDir string
+	var verbose bool
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML, JSON or JSON Lines file that is output by the cmd/parse command in this project. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
+	flag.StringVar(&outputFilePath, "output-file-path", "", "Output file. If empty or -, the output is written to the standard output.")
+	flag.StringVar(&sourceFilter, "source-filter", "", "Regular expression for filtering the source of clippings. If empty, all sources are exported.")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
+	flag.StringVar(&markupFilePath, "markup-file-path", "", "YAML file which maps tags to their roles. If empty, the tags described in the README are used.")
+	flag.StringVar(&templatePath, "template", "", "Template file which replaces the default template library.org.tmpl")
+	flag.StringVar(&templateDir, "template-dir", "", "Directory with template files which replace the default templates of the same name")
+	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	flag.Parse()
+
+	if inputFilePath == "" {
+		flag.PrintDefaults()
+		return errors.New("input file path must be non-empty")
+	}
+
+	if outputFilePath != "" {
+		if err := clippingsio.CheckOutput(outputFilePath); err != nil {
+			return fmt.Errorf("output file path must not exist before this script runs > %w", err)
+		}
+	}
+
+	var sourceFilterRx *regexp.Regexp
+	if sourceFilter != "" {
+		sfRx, err := regexp.Compile(sourceFilter)
+		if err != nil {
+			return fmt.Errorf("supplied source filter '%s' is an invalid regular expression > %w", sourceFilter, err)
+		}
+		sourceFilterRx = sfRx
+	}
+
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
+		return fmt.Errorf("input file must point to a valid file > %w", err)
+	}
+
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
+	logger, err := zap.NewProduction()
+	if verbose {
+		logger, err = zap.NewDevelopment()
+	}
+	if err != nil {
+		return fmt.Errorf("could not create logger > %w", err)
+	}
+
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read source aliases > %w", err)
+	}
+
+	vocabulary, err := markup.ReadMarkup(markupFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read markup > %w", err)
+	}
+
+	logger.Info("reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
+
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
+	if err != nil {
+		return fmt.Errorf("could not read parsed clippings > %w", err)
+	}
+
+	clippings = aliases.Apply(clippings)
+
+	logger.Info("read clippings", zap.Int("clipping_count", len(clippings)))
+
+	if sourceFilterRx != nil {
+		clippings = utils.FilterBySourceRegex(clippings, sourceFilterRx.Copy())
+	}
+
+	data := TemplateData{
+		Books: library.Books(clippings, vocabulary),
+	}
+
+	overrides := templates.Overrides{TemplatePath: templatePath, TemplateDir: templateDir}
+	tmpl, err := templates.Parse(defaultTemplates, overrides, templates.FuncMap(vocabulary), "library.org.tmpl", "properties.org.tmpl")
+	if err != nil {
+		return fmt.Errorf("error while parsing the template file > %w", err)
+	}
+
+	var output io.Writer = os.Stdout
+	if outputFilePath != "" && outputFilePath != clippingsio.Stdio {
+		outputFile, err := os.Create(outputFilePath)
+		if err != nil {
+			return fmt.Errorf("could not create output file > %w", err)
+		}
+		defer outputFile.Close()
+		output = outputFile
+	}
+
+	if err := tmpl.Execute(output, data); err != nil {
+		return fmt.Errorf("error while executing the template > %w", err)
+	}
+
+	logger.Info("exported clippings", zap.Int("book_count", len(data.Books)))
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/export"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/library"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ExitOK int = iota
+	ExitErr
+)
+
+func main() {
+	err := _main()
+	if err != nil {
+		log.Println(fmt.Errorf("error from _main: %w", err))
+		os.Exit(ExitErr)
+	}
+
+	os.Exit(ExitOK)
+}
+
+func _main() error {
+	var inputFilePath, inputFormat, outputDirPath, format, sourceFilter, aliasesFilePath, markupFilePath string
+	var verbose bool
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML, JSON or JSON Lines file that is output by the cmd/parse command in this project. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
+	flag.StringVar(&outputDirPath, "output-dir-path", "", "Output directory, e.g. the pages directory of a Logseq graph or the org-roam directory. One file is written for each book.")
+	flag.StringVar(&format, "format", "logseq", "Output format. One of logseq and org-roam")
+	flag.StringVar(&sourceFilter, "source-filter", "", "Regular expression for filtering the source of clippings. If empty, all sources are exported.")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
+	flag.StringVar(&markupFilePath, "markup-file-path", "", "YAML file which maps tags to their roles. If empty, the tags described in the README are used.")
+	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	flag.Parse()
+
+	if inputFilePath == "" {
+		flag.PrintDefaults()
+		return errors.New("input file path must be non-empty")
+	}
+
+	if outputDirPath == "" {
+		flag.PrintDefaults()
+		return errors.New("output directory path must be non-empty")
+	}
+
+	switch format {
+	case "logseq", "org-roam":
+	default:
+		flag.PrintDefaults()
+		return fmt.Errorf("format must be one of logseq and org-roam, not '%s'", format)
+	}
+
+	var sourceFilterRx *regexp.Regexp
+	if sourceFilter != "" {
+		sfRx, err := regexp.Compile(sourceFilter)
+		if err != nil {
+			return fmt.Errorf("supplied source filter '%s' is an invalid regular expression > %w", sourceFilter, err)
+		}
+		sourceFilterRx = sfRx
+	}
+
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
+		return fmt.Errorf("input file must point to a valid file > %w", err)
+	}
+
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
+	logger, err := zap.NewProduction()
+	if verbose {
+		logger, err = zap.NewDevelopment()
+	}
+	if err != nil {
+		return fmt.Errorf("could not create logger > %w", err)
+	}
+
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read source aliases > %w", err)
+	}
+
+	vocabulary, err := markup.ReadMarkup(markupFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read markup > %w", err)
+	}
+
+	logger.Info("reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
+
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
+	if err != nil {
+		return fmt.Errorf("could not read parsed clippings > %w", err)
+	}
+
+	clippings = aliases.Apply(clippings)
+
+	logger.Info("read clippings", zap.Int("clipping_count", len(clippings)))
+
+	if sourceFilterRx != nil {
+		clippings = utils.FilterBySourceRegex(clippings, sourceFilterRx.Copy())
+	}
+
+	exportLogger := logger.With(zap.String("component", "export"), zap.String("format", format))
+	var exporter interface {
+		Export([]library.Book) (export.Result, error)
+	}
+	switch format {
+	case "logseq":
+		exporter = &export.Logseq{DirPath: outputDirPath, Logger: exportLogger}
+	case "org-roam":
+		exporter = &export.OrgRoam{DirPath: outputDirPath, Logger: exportLogger}
+	}
+
+	result, err := exporter.Export(library.Books(clippings, vocabulary))
+	if err != nil {
+		return fmt.Errorf("could not export clippings to %s > %w", format, err)
+	}
+
+	logger.Info("exported clippings", zap.Int("written", result.Written), zap.Int("unchanged", result.Unchanged))
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"embed"
 	"errors"
 	"flag"
 	"fmt"
@@ -8,11 +9,12 @@ import (
 	"os"
 	"regexp"
 	"sort"
-	"text/template"
 
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/templates"
 	"go.uber.org/zap"
-	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -30,11 +32,26 @@ func main() {
 	os.Exit(ExitOK)
 }
 
+// defaultTemplates are used when they are not replaced with the -template and -template-dir flags.
+//
+//go:embed identify-duplicate-pairs.html.tmpl
+var defaultTemplates embed.FS
+
+// TemplateData is the data of the identify-duplicate-pairs.html.tmpl template. Each pair has the
+// latest version of a note first and the older version second.
+type TemplateData struct {
+	ClippingPairs []parser.Clippings
+}
+
 func _main() error {
-	var inputFilePath, sourceFilter string
+	var inputFilePath, inputFormat, sourceFilter, aliasesFilePath, templatePath, templateDir string
 	var verbose bool
-	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML file that is output by the cmd/parse command in this project.")
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML, JSON or JSON Lines file that is output by the cmd/parse command in this project. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
 	flag.StringVar(&sourceFilter, "source-filter", "", "Regular expression for filtering the source of clippings")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
+	flag.StringVar(&templatePath, "template", "", "Template file which replaces the default template")
+	flag.StringVar(&templateDir, "template-dir", "", "Directory with template files which replace the default templates of the same name")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
 	flag.Parse()
 
@@ -52,10 +69,16 @@ func _main() error {
 		sourceFilterRx = sfRx
 	}
 
-	if _, err := os.Stat(inputFilePath); err != nil {
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
 		return fmt.Errorf("input file must point to a valid file > %w", err)
 	}
 
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
 	logger, err := zap.NewProduction()
 	if verbose {
 		logger, err = zap.NewDevelopment()
@@ -64,20 +87,20 @@ func _main() error {
 		return fmt.Errorf("could not create logger > %w", err)
 	}
 
-	logger.Info("Reading clippings from YAML file", zap.String("file", inputFilePath))
-
-	inputFile, err := os.Open(inputFilePath)
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
 	if err != nil {
-		return fmt.Errorf("could not create output yaml file > %w", err)
+		return fmt.Errorf("could not read source aliases > %w", err)
 	}
-	defer inputFile.Close()
 
-	reader := yaml.NewDecoder(inputFile)
-	var clippings parser.Clippings
-	if err := reader.Decode(&clippings); err != nil {
-		return fmt.Errorf("could not encode parsed clippings into YAML > %w", err)
+	logger.Info("Reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
+
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
+	if err != nil {
+		return fmt.Errorf("could not read parsed clippings > %w", err)
 	}
 
+	clippings = aliases.Apply(clippings)
+
 	logger.Info("read clippings", zap.Int("clipping_count", len(clippings)))
 
 	clippingIndex := make(map[string][]parser.Clipping)
@@ -99,10 +122,6 @@ func _main() error {
 		clippingIndex[clippingKey] = append(clippingIndex[clippingKey], clipping)
 	}
 
-	type TemplateData struct {
-		ClippingPairs []parser.Clippings
-	}
-
 	var data TemplateData
 	data.ClippingPairs = make([]parser.Clippings, 0)
 
@@ -119,7 +138,7 @@ func _main() error {
 		}
 	}
 
-	tmpl, err := template.ParseFiles("./cmd/identify-duplicate-pairs/identify-duplicate-pairs.html.tmpl")
+	tmpl, err := templates.Parse(defaultTemplates, templates.Overrides{TemplatePath: templatePath, TemplateDir: templateDir}, templates.FuncMap(markup.Default()), "identify-duplicate-pairs.html.tmpl")
 	if err != nil {
 		return fmt.Errorf("error while parsing the input template file > %w", err)
 	}
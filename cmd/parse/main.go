@@ -7,11 +7,10 @@ import (
 	"os"
 	"sort"
 
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/duplicates"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
 	"go.uber.org/zap"
-
-	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -30,12 +29,15 @@ func main() {
 }
 
 func _main() error {
-	var inputFilePath, outputFilePath string
-	var verbose, removeDuplicates, removeClippingLimit bool
+	var inputFilePath, outputFilePath, outputFormat, aliasesFilePath string
+	var verbose, removeDuplicates, removeClippingLimit, keepBookmarks bool
 	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Supports the My Clippings.txt file from any Kindle")
-	flag.StringVar(&outputFilePath, "output-file-path", "", "Output file. Output will be written in the YAML format.")
+	flag.StringVar(&outputFilePath, "output-file-path", "", "Output file. Output will be written in the format of -output-format.")
+	flag.StringVar(&outputFormat, "output-format", "", "Format of the output file: "+clippingsio.FormatFlagUsage)
 	flag.BoolVar(&removeClippingLimit, "remove-clipping-limit", false, "Remove clippings which indicate that the clipping text was not saved to the text file")
+	flag.BoolVar(&keepBookmarks, "keep-bookmarks", false, "Keep bookmarks as clippings of type 3, e.g. to write the file back with cmd/export-kindle")
 	flag.BoolVar(&removeDuplicates, "remove-duplicates", false, "Remove duplicate clippings of type Highlight from the generated YAML file")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
 	flag.Parse()
 
@@ -51,8 +53,14 @@ func _main() error {
 		return errors.New("output file path must be non-empty")
 	}
 
-	if _, err := os.Stat(outputFilePath); err == nil {
-		return errors.New("output file path must not exist before this script runs")
+	if err := clippingsio.CheckOutput(outputFilePath); err != nil {
+		return fmt.Errorf("output file path must not exist before this script runs > %w", err)
+	}
+
+	outputFileFormat, err := clippingsio.DetectFormat(outputFilePath, outputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid output format > %w", err)
 	}
 
 	logger, err := zap.NewProduction()
@@ -63,7 +71,14 @@ func _main() error {
 		return fmt.Errorf("could not create logger > %w", err)
 	}
 
-	processor := parser.NewParserWithLogger(inputFilePath, removeClippingLimit, logger.With(zap.String("component", "processor")))
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read source aliases > %w", err)
+	}
+
+	processor := parser.NewKindleClippingsWithLogger(inputFilePath, aliases, logger.With(zap.String("component", "processor")))
+	processor.RemoveClippingLimitClippings = removeClippingLimit
+	processor.KeepBookmarks = keepBookmarks
 
 	clippings, err := processor.Parse()
 	if err != nil {
@@ -86,16 +101,8 @@ func _main() error {
 
 	sort.Sort(clippings)
 
-	outputFile, err := os.Create(outputFilePath)
-	if err != nil {
-		return fmt.Errorf("could not create output yaml file > %w", err)
-	}
-	defer outputFile.Close()
-
-	writer := yaml.NewEncoder(outputFile)
-	defer writer.Close()
-	if err := writer.Encode(clippings); err != nil {
-		return fmt.Errorf("could not encode parsed clippings into YAML > %w", err)
+	if err := clippingsio.WriteFile(outputFilePath, outputFileFormat, clippings); err != nil {
+		return fmt.Errorf("could not write parsed clippings > %w", err)
 	}
 
 	return nil
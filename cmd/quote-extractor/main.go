@@ -1,20 +1,25 @@
 package main
 
 import (
+	"embed"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	htmltemplate "html/template"
+	"io"
 	"log"
 	"os"
 	"regexp"
 	"sort"
 	"strings"
-	"text/template"
 
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/templates"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
 	"go.uber.org/zap"
-	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -32,11 +37,31 @@ func main() {
 	os.Exit(ExitOK)
 }
 
+// defaultTemplates are used when they are not replaced with the -template and -template-dir flags.
+//
+//go:embed quotes.org.tmpl quotes.md.tmpl quotes.html.tmpl
+var defaultTemplates embed.FS
+
+// TemplateData is the data of the quotes.org.tmpl, quotes.md.tmpl and quotes.html.tmpl templates.
+type TemplateData struct {
+	// Books have the quotes grouped by book, sorted by source.
+	Books []Book
+
+	// Clippings is the flat list of the quotes of all the books, in the same order.
+	Clippings parser.Clippings
+}
+
 func _main() error {
-	var inputFilePath, sourceFilter string
+	var inputFilePath, inputFormat, sourceFilter, format, aliasesFilePath, markupFilePath, templatePath, templateDir string
 	var verbose bool
-	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML file that is output by the cmd/parse command in this project.")
-	flag.StringVar(&sourceFilter, "source-filter", "", "Regular expression for filtering the source of clippings")
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML, JSON or JSON Lines file that is output by the cmd/parse command in this project. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
+	flag.StringVar(&sourceFilter, "source-filter", "", "Regular expression for filtering the source of clippings. If empty, quotes are extracted from all sources.")
+	flag.StringVar(&format, "format", "org", "Output format. One of org, markdown, html and json")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
+	flag.StringVar(&markupFilePath, "markup-file-path", "", "YAML file which maps tags to their roles. If empty, the tags described in the README are used.")
+	flag.StringVar(&templatePath, "template", "", "Template file which replaces the default template of the format")
+	flag.StringVar(&templateDir, "template-dir", "", "Directory with template files which replace the default templates of the same name")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
 	flag.Parse()
 
@@ -45,22 +70,38 @@ func _main() error {
 		return errors.New("input file path must be non-empty")
 	}
 
-	if sourceFilter == "" {
-		flag.PrintDefaults()
-		return errors.New("source filter can not be empty")
+	var sourceFilterRx *regexp.Regexp
+	if sourceFilter != "" {
+		sfRx, err := regexp.Compile(sourceFilter)
+		if err != nil {
+			return fmt.Errorf("supplied source filter '%s' is an invalid regular expression > %w", sourceFilter, err)
+		}
+		sourceFilterRx = sfRx
 	}
 
-	var sourceFilterRx *regexp.Regexp
-	sfRx, err := regexp.Compile(sourceFilter)
-	if err != nil {
-		return fmt.Errorf("supplied source filter '%s' is an invalid regular expression > %w", sourceFilter, err)
+	templateNames := map[string]string{
+		"org":      "quotes.org.tmpl",
+		"markdown": "quotes.md.tmpl",
+		"html":     "quotes.html.tmpl",
 	}
-	sourceFilterRx = sfRx
 
-	if _, err := os.Stat(inputFilePath); err != nil {
+	switch format {
+	case "org", "markdown", "html", "json":
+	default:
+		flag.PrintDefaults()
+		return fmt.Errorf("format must be one of org, markdown, html and json, not '%s'", format)
+	}
+
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
 		return fmt.Errorf("input file must point to a valid file > %w", err)
 	}
 
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
 	logger, err := zap.NewProduction()
 	if verbose {
 		logger, err = zap.NewDevelopment()
@@ -69,43 +110,63 @@ func _main() error {
 		return fmt.Errorf("could not create logger > %w", err)
 	}
 
-	logger.Info("reading clippings from YAML file", zap.String("file", inputFilePath))
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read source aliases > %w", err)
+	}
 
-	inputFile, err := os.Open(inputFilePath)
+	vocabulary, err := markup.ReadMarkup(markupFilePath)
 	if err != nil {
-		return fmt.Errorf("could not create output yaml file > %w", err)
+		return fmt.Errorf("could not read markup > %w", err)
 	}
-	defer inputFile.Close()
 
-	reader := yaml.NewDecoder(inputFile)
-	var clippings parser.Clippings
-	if err := reader.Decode(&clippings); err != nil {
-		return fmt.Errorf("could not encode parsed clippings into YAML > %w", err)
+	logger.Info("reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
+
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
+	if err != nil {
+		return fmt.Errorf("could not read parsed clippings > %w", err)
 	}
 
+	clippings = aliases.Apply(clippings)
+
 	logger.Info("read clippings", zap.Int("clipping_count", len(clippings)))
 
-	clippings = utils.FilterBySourceRegex(clippings, sourceFilterRx.Copy())
+	if sourceFilterRx != nil {
+		clippings = utils.FilterBySourceRegex(clippings, sourceFilterRx.Copy())
+	}
 
-	sort.Sort(clippings)
+	books := extractQuotes(logger, clippings, vocabulary)
 
-	quoteClippings := make(parser.Clippings, 0)
-	for i, clipping := range clippings {
-		if clipping.Type == parser.ClippingType_Note && strings.HasPrefix(clipping.Text, "#quote") {
-			if clippings[i-1].Type == parser.ClippingType_Highlight {
-				quoteClippings = append(quoteClippings, clippings[i-1])
-			}
-		}
+	var data TemplateData
+	data.Books = books
+	for _, book := range books {
+		data.Clippings = append(data.Clippings, book.Quotes...)
 	}
 
-	type TemplateData struct {
-		Clippings parser.Clippings
-	}
+	logger.Info("extracted quotes", zap.Int("book_count", len(data.Books)), zap.Int("quote_count", len(data.Clippings)))
 
-	var data TemplateData
-	data.Clippings = quoteClippings
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(data.Books); err != nil {
+			return fmt.Errorf("could not encode quotes into JSON > %w", err)
+		}
+
+		return nil
+	}
 
-	tmpl, err := template.ParseFiles("./cmd/quote-extractor/quotes.org.tmpl")
+	var tmpl interface {
+		Execute(io.Writer, any) error
+	}
+	overrides := templates.Overrides{TemplatePath: templatePath, TemplateDir: templateDir}
+	funcs := templates.FuncMap(vocabulary)
+	funcs["kindleURL"] = kindleURL
+	if format == "html" {
+		tmpl, err = templates.ParseHTML(defaultTemplates, overrides, funcs, templateNames[format])
+	} else {
+		tmpl, err = templates.Parse(defaultTemplates, overrides, funcs, templateNames[format])
+	}
 	if err != nil {
 		return fmt.Errorf("error while parsing the input template file > %w", err)
 	}
@@ -117,3 +178,61 @@ func _main() error {
 
 	return nil
 }
+
+// Book is a source and the highlights in it which were marked as quotes, in the order in which they
+// appear in the book.
+type Book struct {
+	Source string           `json:"source"`
+	Quotes parser.Clippings `json:"quotes"`
+}
+
+// extractQuotes returns the highlights which have a quote note attached to them, grouped by source.
+// Notes which are not attached to any highlight are logged and skipped.
+func extractQuotes(logger *zap.Logger, input parser.Clippings, vocabulary markup.Markup) []Book {
+	clippings := make(parser.Clippings, len(input))
+	copy(clippings, input)
+	sort.Sort(clippings)
+
+	var books []Book
+	quoted := make(map[int]bool)
+	for i, clipping := range clippings {
+		if clipping.Type != parser.ClippingType_Note {
+			continue
+		}
+
+		if _, ok := vocabulary.Is(clipping.Text, markup.Role_Quote); !ok {
+			continue
+		}
+
+		j, ok := clippings.HighlightForNote(i)
+		if !ok {
+			logger.Warn("quote note is not attached to a highlight", zap.String("source", clipping.Source), zap.Int("location", clipping.LocationInSource.Start))
+			continue
+		}
+
+		// The same highlight can have more than one quote note.
+		if quoted[j] {
+			continue
+		}
+		quoted[j] = true
+
+		// Clippings are sorted by source, so all the quotes of a book are next to each other.
+		if len(books) == 0 || books[len(books)-1].Source != clipping.Source {
+			books = append(books, Book{Source: clipping.Source})
+		}
+		books[len(books)-1].Quotes = append(books[len(books)-1].Quotes, clippings[j])
+	}
+
+	return books
+}
+
+// kindleURL marks a kindle:// link as safe to use in HTML. html/template only allows http, https
+// and mailto links, and replaces all other links with "#ZgotmplZ". Other links are dropped, so that
+// the templates of every format only link to the book on the Kindle.
+func kindleURL(url string) htmltemplate.URL {
+	if !strings.HasPrefix(url, "kindle://") {
+		return ""
+	}
+
+	return htmltemplate.URL(url)
+}
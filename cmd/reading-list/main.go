@@ -0,0 +1,156 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/readinglist"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/templates"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ExitOK int = iota
+	ExitErr
+)
+
+func main() {
+	err := _main()
+	if err != nil {
+		log.Println(fmt.Errorf("error from _main: %w", err))
+		os.Exit(ExitErr)
+	}
+
+	os.Exit(ExitOK)
+}
+
+// defaultTemplates are used when they are not replaced with the -template and -template-dir flags.
+//
+//go:embed reading-list.org.tmpl reading-list.md.tmpl
+var defaultTemplates embed.FS
+
+// TemplateData is the data of the reading-list.org.tmpl and reading-list.md.tmpl templates. Entries
+// are sorted by title, and entries without a title are at the end.
+type TemplateData struct {
+	Entries []readinglist.Entry
+}
+
+func _main() error {
+	var inputFilePath, inputFormat, sourceFilter, format, aliasesFilePath, markupFilePath, templatePath, templateDir string
+	var verbose bool
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML, JSON or JSON Lines file that is output by the cmd/parse command in this project. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
+	flag.StringVar(&sourceFilter, "source-filter", "", "Regular expression for filtering the source of clippings. If empty, the reading list is built from all sources.")
+	flag.StringVar(&format, "format", "org", "Output format. One of org, markdown, csv and bibtex")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
+	flag.StringVar(&markupFilePath, "markup-file-path", "", "YAML file which maps tags to their roles. If empty, the tags described in the README are used.")
+	flag.StringVar(&templatePath, "template", "", "Template file which replaces the default template of the format")
+	flag.StringVar(&templateDir, "template-dir", "", "Directory with template files which replace the default templates of the same name")
+	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	flag.Parse()
+
+	if inputFilePath == "" {
+		flag.PrintDefaults()
+		return errors.New("input file path must be non-empty")
+	}
+
+	var sourceFilterRx *regexp.Regexp
+	if sourceFilter != "" {
+		sfRx, err := regexp.Compile(sourceFilter)
+		if err != nil {
+			return fmt.Errorf("supplied source filter '%s' is an invalid regular expression > %w", sourceFilter, err)
+		}
+		sourceFilterRx = sfRx
+	}
+
+	templateNames := map[string]string{
+		"org":      "reading-list.org.tmpl",
+		"markdown": "reading-list.md.tmpl",
+	}
+
+	switch format {
+	case "org", "markdown", "csv", "bibtex":
+	default:
+		flag.PrintDefaults()
+		return fmt.Errorf("format must be one of org, markdown, csv and bibtex, not '%s'", format)
+	}
+
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
+		return fmt.Errorf("input file must point to a valid file > %w", err)
+	}
+
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
+	logger, err := zap.NewProduction()
+	if verbose {
+		logger, err = zap.NewDevelopment()
+	}
+	if err != nil {
+		return fmt.Errorf("could not create logger > %w", err)
+	}
+
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read source aliases > %w", err)
+	}
+
+	vocabulary, err := markup.ReadMarkup(markupFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read markup > %w", err)
+	}
+
+	logger.Info("reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
+
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
+	if err != nil {
+		return fmt.Errorf("could not read parsed clippings > %w", err)
+	}
+
+	clippings = aliases.Apply(clippings)
+
+	logger.Info("read clippings", zap.Int("clipping_count", len(clippings)))
+
+	if sourceFilterRx != nil {
+		clippings = utils.FilterBySourceRegex(clippings, sourceFilterRx.Copy())
+	}
+
+	entries := readinglist.Extract(clippings, vocabulary)
+
+	logger.Info("extracted reading list", zap.Int("entry_count", len(entries)))
+
+	switch format {
+	case "csv":
+		if err := readinglist.WriteCSV(os.Stdout, entries); err != nil {
+			return fmt.Errorf("could not write reading list as CSV > %w", err)
+		}
+	case "bibtex":
+		if err := readinglist.WriteBibTeX(os.Stdout, entries); err != nil {
+			return fmt.Errorf("could not write reading list as BibTeX > %w", err)
+		}
+	default:
+		tmpl, err := templates.Parse(defaultTemplates, templates.Overrides{TemplatePath: templatePath, TemplateDir: templateDir}, templates.FuncMap(vocabulary), templateNames[format])
+		if err != nil {
+			return fmt.Errorf("error while parsing the input template file > %w", err)
+		}
+
+		err = tmpl.Execute(os.Stdout, TemplateData{Entries: entries})
+		if err != nil {
+			return fmt.Errorf("error while executing text template > %w", err)
+		}
+	}
+
+	return nil
+}
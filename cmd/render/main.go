@@ -0,0 +1,297 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/library"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/summarizer"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/templates"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ExitOK int = iota
+	ExitErr
+)
+
+func main() {
+	err := _main()
+	if err != nil {
+		log.Println(fmt.Errorf("error from _main: %w", err))
+		os.Exit(ExitErr)
+	}
+
+	os.Exit(ExitOK)
+}
+
+// TemplateData is the data of the template. All three fields are built from the clippings which
+// are left after the filters are applied.
+type TemplateData struct {
+	// Clippings are sorted by source and by location.
+	Clippings parser.Clippings
+
+	// Books are sorted by source.
+	Books []library.Book
+
+	// Summaries are the summaries of the books which have chapter name or chapter summary notes,
+	// sorted by source.
+	Summaries []summarizer.BookSummary
+}
+
+func _main() error {
+	var inputFilePath, inputFormat, outputFilePath, templatePath, templateDir, sourceFilter, clippingType, tag, since, until, aliasesFilePath, markupFilePath string
+	var verbose bool
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML, JSON or JSON Lines file that is output by the cmd/parse command in this project. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
+	flag.StringVar(&outputFilePath, "output-file-path", "", "Output file. If empty or -, the output is written to the standard output.")
+	flag.StringVar(&templatePath, "template", "", "Template file. Templates whose file name ends with .html or .html.tmpl are executed as HTML templates.")
+	flag.StringVar(&templateDir, "template-dir", "", "Directory with template files which can be used by the template with the template action. Only files with the extension .tmpl are read.")
+	flag.StringVar(&sourceFilter, "source-filter", "", "Regular expression for filtering the source of clippings")
+	flag.StringVar(&clippingType, "type", "", "Type of clippings to keep: highlight or note. If empty, clippings of both types are kept.")
+	flag.StringVar(&tag, "tag", "", "Keep only the notes which start with this tag, and the highlights that they are attached to")
+	flag.StringVar(&since, "since", "", "Keep only the clippings created on or after this date, in the format 2006-01-02")
+	flag.StringVar(&until, "until", "", "Keep only the clippings created on or before this date, in the format 2006-01-02")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
+	flag.StringVar(&markupFilePath, "markup-file-path", "", "YAML file which maps tags to their roles. If empty, the tags described in the README are used.")
+	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
+	flag.Parse()
+
+	if inputFilePath == "" {
+		flag.PrintDefaults()
+		return errors.New("input file path must be non-empty")
+	}
+
+	if templatePath == "" {
+		flag.PrintDefaults()
+		return errors.New("template must be non-empty")
+	}
+
+	if outputFilePath != "" {
+		if err := clippingsio.CheckOutput(outputFilePath); err != nil {
+			return fmt.Errorf("output file path must not exist before this script runs > %w", err)
+		}
+	}
+
+	var sourceFilterRx *regexp.Regexp
+	if sourceFilter != "" {
+		sfRx, err := regexp.Compile(sourceFilter)
+		if err != nil {
+			return fmt.Errorf("supplied source filter '%s' is an invalid regular expression > %w", sourceFilter, err)
+		}
+		sourceFilterRx = sfRx
+	}
+
+	var keepType parser.ClippingType
+	switch clippingType {
+	case "":
+		keepType = parser.ClippingType_None
+	case "highlight":
+		keepType = parser.ClippingType_Highlight
+	case "note":
+		keepType = parser.ClippingType_Note
+	default:
+		flag.PrintDefaults()
+		return fmt.Errorf("type must be highlight or note, not '%s'", clippingType)
+	}
+
+	var sinceTime, untilTime time.Time
+	if since != "" {
+		t, err := time.ParseInLocation("2006-01-02", since, time.Local)
+		if err != nil {
+			return fmt.Errorf("since must be a date in the format 2006-01-02 > %w", err)
+		}
+		sinceTime = t
+	}
+	if until != "" {
+		t, err := time.ParseInLocation("2006-01-02", until, time.Local)
+		if err != nil {
+			return fmt.Errorf("until must be a date in the format 2006-01-02 > %w", err)
+		}
+		// Clippings created at any time on the last day are kept.
+		untilTime = t.AddDate(0, 0, 1)
+	}
+
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
+		return fmt.Errorf("input file must point to a valid file > %w", err)
+	}
+
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
+	logger, err := zap.NewProduction()
+	if verbose {
+		logger, err = zap.NewDevelopment()
+	}
+	if err != nil {
+		return fmt.Errorf("could not create logger > %w", err)
+	}
+
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read source aliases > %w", err)
+	}
+
+	vocabulary, err := markup.ReadMarkup(markupFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read markup > %w", err)
+	}
+
+	logger.Info("reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
+
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
+	if err != nil {
+		return fmt.Errorf("could not read parsed clippings > %w", err)
+	}
+
+	clippings = aliases.Apply(clippings)
+
+	logger.Info("read clippings", zap.Int("clipping_count", len(clippings)))
+
+	if sourceFilterRx != nil {
+		clippings = utils.FilterBySourceRegex(clippings, sourceFilterRx.Copy())
+	}
+
+	sort.Sort(clippings)
+
+	if tag != "" {
+		clippings = filterByTag(clippings, tag)
+	}
+
+	filtered := make(parser.Clippings, 0, len(clippings))
+	for _, clipping := range clippings {
+		if keepType != parser.ClippingType_None && clipping.Type != keepType {
+			continue
+		}
+		if !sinceTime.IsZero() && clipping.CreateTime.Before(sinceTime) {
+			continue
+		}
+		if !untilTime.IsZero() && !clipping.CreateTime.Before(untilTime) {
+			continue
+		}
+		filtered = append(filtered, clipping)
+	}
+
+	logger.Info("filtered clippings", zap.Int("clipping_count", len(filtered)))
+
+	var data TemplateData
+	data.Clippings = filtered
+	data.Books = library.Books(filtered, vocabulary)
+
+	summaryCreator := summarizer.KindleCreator{
+		Markup: vocabulary,
+		Logger: logger.With(zap.String("component", "summarizer")),
+	}
+
+	for _, book := range data.Books {
+		if !vocabulary.HasRole(book.Clippings, markup.Role_ChapterName, markup.Role_ChapterSummary) {
+			continue
+		}
+
+		summary, err := summaryCreator.Summarize(book.Clippings)
+		if err != nil {
+			logger.Warn("could not create summary", zap.String("source", book.Source), zap.Error(err))
+			continue
+		}
+
+		data.Summaries = append(data.Summaries, summary)
+	}
+
+	// The template can use the other templates in the template directory by their file names. Only
+	// files with the extension .tmpl are templates, so that other files in the directory, e.g. a
+	// README or the backup files of an editor, are not parsed.
+	names := []string{filepath.Base(templatePath)}
+	var partials fs.FS
+	if templateDir != "" {
+		partials = os.DirFS(templateDir)
+		entries, err := fs.ReadDir(partials, ".")
+		if err != nil {
+			return fmt.Errorf("could not list the files in the template directory > %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && filepath.Ext(entry.Name()) == ".tmpl" && entry.Name() != names[0] {
+				names = append(names, entry.Name())
+			}
+		}
+	}
+
+	overrides := templates.Overrides{TemplatePath: templatePath}
+	var tmpl interface {
+		Execute(io.Writer, any) error
+	}
+	if strings.HasSuffix(names[0], ".html") || strings.HasSuffix(names[0], ".html.tmpl") {
+		tmpl, err = templates.ParseHTML(partials, overrides, templates.FuncMap(vocabulary), names...)
+	} else {
+		tmpl, err = templates.Parse(partials, overrides, templates.FuncMap(vocabulary), names...)
+	}
+	if err != nil {
+		return fmt.Errorf("error while parsing the template file > %w", err)
+	}
+
+	var output io.Writer = os.Stdout
+	if outputFilePath != "" && outputFilePath != clippingsio.Stdio {
+		outputFile, err := os.Create(outputFilePath)
+		if err != nil {
+			return fmt.Errorf("could not create output file > %w", err)
+		}
+		defer outputFile.Close()
+		output = outputFile
+	}
+
+	if err := tmpl.Execute(output, data); err != nil {
+		return fmt.Errorf("error while executing the template > %w", err)
+	}
+
+	return nil
+}
+
+// filterByTag returns the notes which start with the tag and the highlights that they are attached
+// to. The input must be sorted.
+func filterByTag(input parser.Clippings, tag string) parser.Clippings {
+	if !strings.HasPrefix(tag, "#") {
+		tag = "#" + tag
+	}
+	tagMarkup := markup.Markup{tag: markup.Role_Custom}
+
+	keep := make(map[int]bool)
+	for i, clipping := range input {
+		if clipping.Type != parser.ClippingType_Note {
+			continue
+		}
+
+		if _, _, _, ok := tagMarkup.Match(clipping.Text); !ok {
+			continue
+		}
+
+		keep[i] = true
+		if j, ok := input.HighlightForNote(i); ok {
+			keep[j] = true
+		}
+	}
+
+	output := make(parser.Clippings, 0, len(keep))
+	for i, clipping := range input {
+		if keep[i] {
+			output = append(output, clipping)
+		}
+	}
+
+	return output
+}
@@ -1,18 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"text/template"
 
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/summarizer"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/templates"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
 	"go.uber.org/zap"
-	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -28,11 +35,22 @@ func main() {
 	}
 }
 
+// defaultTemplates are used when they are not replaced with the -template and -template-dir flags.
+//
+//go:embed summary.org.tmpl chapter.org.tmpl
+var defaultTemplates embed.FS
+
 func _main() error {
-	var inputFilePath, sourceFilter string
+	var inputFilePath, inputFormat, sourceFilter, aliasesFilePath, markupFilePath, outputDirPath, templatePath, templateDir string
 	var verbose bool
-	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. YAML file output from the parse command")
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. YAML, JSON or JSON Lines file output from the parse command. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
 	flag.StringVar(&sourceFilter, "source-filter", "", "Regular expression for filtering the source of clippings")
+	flag.StringVar(&outputDirPath, "output-dir-path", "", "Output directory. If set, a summary is built for every source with chapter markers which matches the source filter, and written to a separate file in this directory. Every summary is built on every run, but a file is only written when its content changes.")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
+	flag.StringVar(&markupFilePath, "markup-file-path", "", "YAML file which maps tags to their roles. If empty, the tags described in the README are used.")
+	flag.StringVar(&templatePath, "template", "", "Template file which replaces the default template")
+	flag.StringVar(&templateDir, "template-dir", "", "Directory with template files which replace the default templates of the same name")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
 	flag.Parse()
 
@@ -50,10 +68,16 @@ func _main() error {
 		sourceFilterRx = sfRx
 	}
 
-	if _, err := os.Stat(inputFilePath); err != nil {
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
 		return fmt.Errorf("input file must point to a valid file > %w", err)
 	}
 
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
 	logger, err := zap.NewProduction()
 	if verbose {
 		logger, err = zap.NewDevelopment()
@@ -62,22 +86,43 @@ func _main() error {
 		return fmt.Errorf("could not create logger > %w", err)
 	}
 
-	logger.Info("read clippings from YAML file", zap.String("file", inputFilePath))
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read source aliases > %w", err)
+	}
 
-	inputFile, err := os.Open(inputFilePath)
+	vocabulary, err := markup.ReadMarkup(markupFilePath)
 	if err != nil {
-		return fmt.Errorf("could not create output yaml file > %w", err)
+		return fmt.Errorf("could not read markup > %w", err)
 	}
-	defer inputFile.Close()
 
-	reader := yaml.NewDecoder(inputFile)
-	var clippings parser.Clippings
-	if err := reader.Decode(&clippings); err != nil {
-		return fmt.Errorf("could not encode parsed clippings into YAML > %w", err)
+	logger.Info("reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
+
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
+	if err != nil {
+		return fmt.Errorf("could not read parsed clippings > %w", err)
 	}
 
+	clippings = aliases.Apply(clippings)
+
 	logger.Info("read clippings", zap.Int("clipping_count", len(clippings)))
 
+	// The data of summary.org.tmpl is a summarizer.BookSummary, and the data of chapter.org.tmpl is
+	// a summarizer.ChapterSummary.
+	tmpl, err := templates.Parse(defaultTemplates, templates.Overrides{TemplatePath: templatePath, TemplateDir: templateDir}, templates.FuncMap(vocabulary), "summary.org.tmpl", "chapter.org.tmpl")
+	if err != nil {
+		return fmt.Errorf("error reading the template files > %w", err)
+	}
+
+	summaryCreator := summarizer.KindleCreator{
+		Markup: vocabulary,
+		Logger: logger.With(zap.String("component", "summarizer")),
+	}
+
+	if outputDirPath != "" {
+		return buildAll(logger, &summaryCreator, vocabulary, tmpl, clippings, sourceFilterRx, outputDirPath)
+	}
+
 	var sourceName string
 	sources := make(map[string]bool)
 	for _, clipping := range clippings {
@@ -94,18 +139,13 @@ func _main() error {
 
 	logger.Info("build summary for source", zap.String("source", sourceName))
 
-	summaryCreator := summarizer.KindleCreator{
-		Logger: logger.With(zap.String("component", "summarizer")),
-	}
-
 	summary, err := summaryCreator.Summarize(utils.FilterBySource(clippings, sourceName))
 	if err != nil {
 		return fmt.Errorf("could not create summary of source '%s' > %w", sourceName, err)
 	}
 
-	tmpl, err := template.ParseFiles("./cmd/summary-builder/summary.org.tmpl", "./cmd/summary-builder/chapter.org.tmpl")
-	if err != nil {
-		return fmt.Errorf("error reading the template files > %w", err)
+	for _, warning := range summary.Warnings {
+		logger.Warn("summary warning", zap.String("source", sourceName), zap.String("warning", warning))
 	}
 
 	err = tmpl.Execute(os.Stdout, summary)
@@ -115,3 +155,91 @@ func _main() error {
 
 	return nil
 }
+
+// buildAll builds the summary of every source which has chapter markers, and writes each summary to
+// a separate file in the output directory. Every summary is built and rendered on every run, but
+// files whose content would not change are not written again, so that this can be run every night
+// without touching the summaries of books which were not read that day.
+func buildAll(logger *zap.Logger, summaryCreator summarizer.Creator, vocabulary markup.Markup, tmpl *template.Template, clippings parser.Clippings, sourceFilterRx *regexp.Regexp, outputDirPath string) error {
+	if err := os.MkdirAll(outputDirPath, 0755); err != nil {
+		return fmt.Errorf("could not create output directory > %w", err)
+	}
+
+	sourceSet := make(map[string]bool)
+	for _, clipping := range clippings {
+		if sourceFilterRx == nil || sourceFilterRx.MatchString(clipping.Source) {
+			sourceSet[clipping.Source] = true
+		}
+	}
+
+	sources := make([]string, 0, len(sourceSet))
+	for source := range sourceSet {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var summarized []string
+	filenameSources := make(map[string][]string)
+	for _, source := range sources {
+		if !vocabulary.HasRole(utils.FilterBySource(clippings, source), markup.Role_ChapterName, markup.Role_ChapterSummary) {
+			continue
+		}
+
+		summarized = append(summarized, source)
+		filename := utils.SafeFilename(source)
+		filenameSources[filename] = append(filenameSources[filename], source)
+	}
+
+	var failed, written, unchanged int
+	for _, source := range summarized {
+		sourceClippings := utils.FilterBySource(clippings, source)
+
+		// Two sources can have the same safe file name, e.g. when they differ only in punctuation. A
+		// hash of the source is appended to the name of every source with that file name, so that the
+		// file of a book does not change when another book with the same file name is added.
+		filename := utils.SafeFilename(source)
+		if others := filenameSources[filename]; len(others) > 1 {
+			logger.Warn("sources have the same file name", zap.String("source", source), zap.Strings("sources", others))
+			sum := sha256.Sum256([]byte(source))
+			filename = fmt.Sprintf("%s-%x", filename, sum[:4])
+		}
+
+		summary, err := summaryCreator.Summarize(sourceClippings)
+		if err != nil {
+			logger.Error("could not create summary", zap.String("source", source), zap.Error(err))
+			failed++
+			continue
+		}
+
+		for _, warning := range summary.Warnings {
+			logger.Warn("summary warning", zap.String("source", source), zap.String("warning", warning))
+		}
+
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, summary); err != nil {
+			return fmt.Errorf("error while executing the templates for source '%s' > %w", source, err)
+		}
+
+		outputFilePath := filepath.Join(outputDirPath, filename+".org")
+		if existing, err := os.ReadFile(outputFilePath); err == nil && bytes.Equal(existing, rendered.Bytes()) {
+			logger.Debug("summary is unchanged", zap.String("source", source), zap.String("file", outputFilePath))
+			unchanged++
+			continue
+		}
+
+		if err := os.WriteFile(outputFilePath, rendered.Bytes(), 0644); err != nil {
+			return fmt.Errorf("could not write summary of source '%s' > %w", source, err)
+		}
+
+		logger.Info("wrote summary", zap.String("source", source), zap.String("file", outputFilePath))
+		written++
+	}
+
+	logger.Info("built summaries", zap.Int("written", written), zap.Int("unchanged", unchanged), zap.Int("failed", failed))
+
+	if failed > 0 {
+		return fmt.Errorf("could not create the summary of %d sources", failed)
+	}
+
+	return nil
+}
@@ -1,18 +1,26 @@
 package main
 
 import (
+	"embed"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
+	"strings"
+	"text/tabwriter"
 
+	"github.com/icyflame/kindle-my-clippings-parser/internal/clippingsio"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/supplementer"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/templates"
 	"go.uber.org/zap"
-	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -30,13 +38,41 @@ func main() {
 	os.Exit(ExitOK)
 }
 
+// defaultTemplates are used when they are not replaced with the -template and -template-dir flags.
+//
+//go:embed report.html.tmpl
+var defaultTemplates embed.FS
+
+// BookReport is the merge report for a single Bookcision file. This is the data that is passed to
+// the HTML report template, as a list with one entry for each file.
+type BookReport struct {
+	File   string `json:"file"`
+	Source string `json:"source"`
+
+	supplementer.Report
+}
+
 func _main() error {
-	var inputFilePath, outputFilePath, supplementFilePath, sourceFilter string
-	var verbose bool
-	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML file that is output by the cmd/parse command in this project.")
-	flag.StringVar(&outputFilePath, "output-file-path", "", "Output file. Output will be written in the YAML format.")
+	var inputFilePath, inputFormat, outputFilePath, outputFormat, supplementFilePath, supplementDirPath, sourceFilter, aliasesFilePath, markupFilePath, deletedReportFilePath, reportFilePath, conflictPolicy, templatePath, templateDir string
+	var locationTolerance int
+	var verbose, addMissing, pruneDeleted bool
+	flag.StringVar(&inputFilePath, "input-file-path", "", "Input file. Input file should be the YAML, JSON or JSON Lines file that is output by the cmd/parse command in this project. Use - for the standard input.")
+	flag.StringVar(&inputFormat, "input-format", "", "Format of the input file: "+clippingsio.FormatFlagUsage)
+	flag.StringVar(&outputFilePath, "output-file-path", "", "Output file, or - for the standard output. Output will be written in the format of -output-format.")
+	flag.StringVar(&outputFormat, "output-format", "", "Format of the output file: "+clippingsio.FormatFlagUsage)
 	flag.StringVar(&supplementFilePath, "supplement-file-path", "", "JSON file with all the clippings, exported using Bookcision")
-	flag.StringVar(&sourceFilter, "source-filter", "", "Regular expression for filtering the source of clippings")
+	flag.StringVar(&supplementDirPath, "supplement-dir-path", "", "Directory with JSON files exported using Bookcision. Each file is matched to a source using the title and authors of the book.")
+	flag.StringVar(&sourceFilter, "source-filter", "", "Regular expression for filtering the source of clippings. If this is empty, the source is matched using the title and authors from the Bookcision file.")
+	flag.IntVar(&locationTolerance, "location-tolerance", 2, "Largest difference between the locations of a Kindle highlight and a Bookcision highlight for them to be matched")
+	flag.BoolVar(&addMissing, "add-missing", false, "Add highlights and notes which are present in the Bookcision file but missing from the input file")
+	flag.BoolVar(&pruneDeleted, "prune-deleted", false, "Remove highlights which are not present in the Bookcision file, because they were deleted on the device")
+	flag.StringVar(&deletedReportFilePath, "deleted-report-file-path", "", "Report file. Highlights which are not present in the Bookcision file, and the notes attached to them, will be written to this file. The format is chosen by the extension: .yaml or .yml, .json, or .jsonl or .ndjson")
+	flag.StringVar(&reportFilePath, "report-file-path", "", "Report file. A report of filled and unfilled placeholders, unmatched Bookcision highlights and conflicts will be written to this file. The format is chosen by the extension: .json or .html")
+	flag.StringVar(&conflictPolicy, "conflict-policy", string(supplementer.ConflictPolicy_Kindle), "Text which is kept when a Kindle highlight and the matching Bookcision highlight have different text: kindle or bookcision")
+	flag.StringVar(&aliasesFilePath, "aliases-file-path", "", "YAML file which maps variant source names to the canonical source name of the same book")
+	flag.StringVar(&markupFilePath, "markup-file-path", "", "YAML file which maps tags to their roles, for the functions of the template of the HTML report. If empty, the tags described in the README are used.")
+	flag.StringVar(&templatePath, "template", "", "Template file which replaces the default template of the HTML report")
+	flag.StringVar(&templateDir, "template-dir", "", "Directory with template files which replace the default templates of the same name")
 	flag.BoolVar(&verbose, "verbose", false, "Enable verbose logging")
 	flag.Parse()
 
@@ -45,9 +81,50 @@ func _main() error {
 		return errors.New("input file path must be non-empty")
 	}
 
-	if supplementFilePath == "" {
+	if locationTolerance < 0 {
+		return errors.New("location tolerance must not be negative")
+	}
+
+	if (supplementFilePath == "") == (supplementDirPath == "") {
 		flag.PrintDefaults()
-		return errors.New("supplement file path must be non-empty")
+		return errors.New("exactly one of supplement file path and supplement directory path must be non-empty")
+	}
+
+	switch supplementer.ConflictPolicy(conflictPolicy) {
+	case supplementer.ConflictPolicy_Kindle, supplementer.ConflictPolicy_Bookcision:
+	default:
+		return fmt.Errorf("conflict policy must be either kindle or bookcision, got '%s'", conflictPolicy)
+	}
+
+	if reportFilePath != "" {
+		switch filepath.Ext(reportFilePath) {
+		case ".json", ".html":
+		default:
+			flag.PrintDefaults()
+			return fmt.Errorf("report file must have the extension .json or .html, got '%s'", reportFilePath)
+		}
+	}
+
+	// The format of the deleted report is chosen only by its extension, so an unknown extension is an
+	// error instead of falling back to YAML like the output file.
+	var deletedReportFormat clippingsio.Format
+	if deletedReportFilePath != "" {
+		switch strings.ToLower(filepath.Ext(deletedReportFilePath)) {
+		case ".yaml", ".yml", ".json", ".jsonl", ".ndjson":
+		default:
+			flag.PrintDefaults()
+			return fmt.Errorf("deleted report file must have the extension .yaml, .yml, .json, .jsonl or .ndjson, got '%s'", deletedReportFilePath)
+		}
+
+		format, err := clippingsio.DetectFormat(deletedReportFilePath, "")
+		if err != nil {
+			return fmt.Errorf("invalid deleted report format > %w", err)
+		}
+		deletedReportFormat = format
+	}
+
+	if sourceFilter != "" && supplementDirPath != "" {
+		return errors.New("source filter can be used only with a single supplement file")
 	}
 
 	var sourceFilterRx *regexp.Regexp
@@ -59,10 +136,22 @@ func _main() error {
 		sourceFilterRx = sfRx
 	}
 
-	if _, err := os.Stat(inputFilePath); err != nil {
+	if err := clippingsio.CheckInput(inputFilePath); err != nil {
 		return fmt.Errorf("input file must point to a valid file > %w", err)
 	}
 
+	inputFileFormat, err := clippingsio.DetectFormat(inputFilePath, inputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid input format > %w", err)
+	}
+
+	outputFileFormat, err := clippingsio.DetectFormat(outputFilePath, outputFormat)
+	if err != nil {
+		flag.PrintDefaults()
+		return fmt.Errorf("invalid output format > %w", err)
+	}
+
 	logger, err := zap.NewProduction()
 	if verbose {
 		logger, err = zap.NewDevelopment()
@@ -71,54 +160,172 @@ func _main() error {
 		return fmt.Errorf("could not create logger > %w", err)
 	}
 
-	logger.Info("Reading clippings from YAML file", zap.String("file", inputFilePath))
+	aliases, err := parser.ReadSourceAliases(aliasesFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read source aliases > %w", err)
+	}
 
-	inputFile, err := os.Open(inputFilePath)
+	vocabulary, err := markup.ReadMarkup(markupFilePath)
 	if err != nil {
-		return fmt.Errorf("could not create output yaml file > %w", err)
+		return fmt.Errorf("could not read markup > %w", err)
 	}
-	defer inputFile.Close()
 
-	reader := yaml.NewDecoder(inputFile)
-	var clippings parser.Clippings
-	if err := reader.Decode(&clippings); err != nil {
-		return fmt.Errorf("could not encode parsed clippings into YAML > %w", err)
+	logger.Info("Reading clippings from file", zap.String("file", inputFilePath), zap.String("format", string(inputFileFormat)))
+
+	clippings, err := clippingsio.ReadFile(inputFilePath, inputFileFormat)
+	if err != nil {
+		return fmt.Errorf("could not read parsed clippings > %w", err)
 	}
 
-	logger.Info("read clippings from parsed YAML file", zap.Int("clipping_count", len(clippings)))
+	clippings = aliases.Apply(clippings)
+
+	logger.Info("read clippings from parsed file", zap.Int("clipping_count", len(clippings)))
 
-	bookcisionParser := parser.BookcisionClippings{
-		FilePath: supplementFilePath,
-		Logger:   logger.With(zap.String("component", "parser"), zap.String("subcomponent", "bookcision")),
+	supplementFilePaths := []string{supplementFilePath}
+	if supplementDirPath != "" {
+		supplementFilePaths, err = filepath.Glob(filepath.Join(supplementDirPath, "*.json"))
+		if err != nil {
+			return fmt.Errorf("could not list JSON files in supplement directory > %w", err)
+		}
+		sort.Strings(supplementFilePaths)
 	}
 
-	bookcisionClippings, err := bookcisionParser.Parse()
-	if err != nil {
-		return fmt.Errorf("could not parse bookcision JSON file '%s' > %w", supplementFilePath, err)
+	sourceSet := make(map[string]bool)
+	for _, clipping := range clippings {
+		sourceSet[clipping.Source] = true
+	}
+	sources := make([]string, 0, len(sourceSet))
+	for source := range sourceSet {
+		sources = append(sources, source)
 	}
+	sort.Strings(sources)
 
-	supplementer := supplementer.Bookcision{
-		SourceRegex: sourceFilterRx.Copy(),
-		Logger:      logger.With(zap.String("component", "supplementer")),
+	// The summary is written to the standard error if the clippings are written to the standard
+	// output, so that it does not end up in the middle of them.
+	var summaryOutput io.Writer = os.Stdout
+	if outputFilePath == clippingsio.Stdio {
+		summaryOutput = os.Stderr
 	}
 
-	supplementedClippings, err := supplementer.Merge(clippings, bookcisionClippings)
-	if err != nil {
-		return fmt.Errorf("could not supplement kindle with bookcision > %w", err)
+	summary := tabwriter.NewWriter(summaryOutput, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(summary, "FILE\tSOURCE\tPLACEHOLDERS\tFILLED\tAMBIGUOUS\tCONFLICTS\tADDED\tDELETED")
+
+	var deleted parser.Clippings
+	var reports []BookReport
+	supplementedClippings := clippings
+	for _, filePath := range supplementFilePaths {
+		bookcisionParser := parser.BookcisionClippings{
+			FilePath: filePath,
+			Logger:   logger.With(zap.String("component", "parser"), zap.String("subcomponent", "bookcision")),
+		}
+
+		set, bookcisionClippings, err := bookcisionParser.ParseSet()
+		if err != nil {
+			return fmt.Errorf("could not parse bookcision JSON file '%s' > %w", filePath, err)
+		}
+
+		bookcisionClippings = aliases.Apply(bookcisionClippings)
+
+		sourceRx, sourceName := sourceFilterRx, sourceFilter
+		if sourceRx == nil {
+			source, err := supplementer.MatchSource(sources, set.Title, set.Authors)
+			if err != nil {
+				if supplementDirPath == "" {
+					return fmt.Errorf("could not find the source for bookcision JSON file '%s' > %w", filePath, err)
+				}
+
+				logger.Warn("skipping bookcision JSON file", zap.String("file", filePath), zap.Error(err))
+				continue
+			}
+
+			logger.Info("matched bookcision JSON file to source", zap.String("file", filePath), zap.String("source", source))
+			sourceRx, sourceName = regexp.MustCompile("^"+regexp.QuoteMeta(source)+"$"), source
+		}
+
+		bookcision := supplementer.Bookcision{
+			SourceRegex:       sourceRx.Copy(),
+			LocationTolerance: locationTolerance,
+			AddMissing:        addMissing,
+			PruneDeleted:      pruneDeleted,
+			ConflictPolicy:    supplementer.ConflictPolicy(conflictPolicy),
+			Logger:            logger.With(zap.String("component", "supplementer"), zap.String("file", filePath)),
+		}
+
+		merged, report, err := bookcision.MergeWithReport(supplementedClippings, bookcisionClippings)
+		if err != nil {
+			return fmt.Errorf("could not supplement kindle with bookcision JSON file '%s' > %w", filePath, err)
+		}
+		supplementedClippings = merged
+
+		for _, ambiguous := range report.Ambiguous {
+			logger.Warn("placeholder was not filled because more than one bookcision highlight matches it",
+				zap.String("source", ambiguous.Kindle.Source),
+				zap.Int("location", ambiguous.Kindle.LocationInSource.Start),
+				zap.Int("candidate_count", len(ambiguous.Candidates)))
+		}
+
+		deleted = append(deleted, report.Deleted...)
+		reports = append(reports, BookReport{
+			File:   filePath,
+			Source: sourceName,
+			Report: report,
+		})
+
+		fmt.Fprintf(summary, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\n",
+			filepath.Base(filePath), sourceName, report.Placeholders, len(report.Filled), len(report.Ambiguous), len(report.Conflicts), len(report.Added), len(report.Deleted))
+	}
+
+	if err := summary.Flush(); err != nil {
+		return fmt.Errorf("could not write summary > %w", err)
+	}
+
+	if deletedReportFilePath != "" {
+		if err := clippingsio.WriteFile(deletedReportFilePath, deletedReportFormat, deleted); err != nil {
+			return fmt.Errorf("could not write deleted report file > %w", err)
+		}
+	}
+
+	if reportFilePath != "" {
+		if err := writeReport(reportFilePath, reports, vocabulary, templates.Overrides{TemplatePath: templatePath, TemplateDir: templateDir}); err != nil {
+			return err
+		}
 	}
 
 	sort.Sort(supplementedClippings)
 
-	outputFile, err := os.Create(outputFilePath)
+	if err := clippingsio.WriteFile(outputFilePath, outputFileFormat, supplementedClippings); err != nil {
+		return fmt.Errorf("could not write supplemented clippings > %w", err)
+	}
+
+	return nil
+}
+
+// writeReport writes the merge reports to the given file, in the format chosen by the extension of
+// the file. The vocabulary is used by the functions of the template of the HTML report.
+func writeReport(reportFilePath string, reports []BookReport, vocabulary markup.Markup, overrides templates.Overrides) error {
+	reportFile, err := os.Create(reportFilePath)
+	if err != nil {
+		return fmt.Errorf("could not create report file > %w", err)
+	}
+	defer reportFile.Close()
+
+	if filepath.Ext(reportFilePath) == ".json" {
+		encoder := json.NewEncoder(reportFile)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			return fmt.Errorf("could not encode merge report into JSON > %w", err)
+		}
+
+		return nil
+	}
+
+	tmpl, err := templates.ParseHTML(defaultTemplates, overrides, templates.FuncMap(vocabulary), "report.html.tmpl")
 	if err != nil {
-		return fmt.Errorf("could not create output yaml file > %w", err)
+		return fmt.Errorf("error while parsing the report template file > %w", err)
 	}
-	defer outputFile.Close()
 
-	writer := yaml.NewEncoder(outputFile)
-	defer writer.Close()
-	if err := writer.Encode(supplementedClippings); err != nil {
-		return fmt.Errorf("could not encode parsed supplementedClippings into YAML > %w", err)
+	if err := tmpl.Execute(reportFile, reports); err != nil {
+		return fmt.Errorf("error while executing the report template > %w", err)
 	}
 
 	return nil
@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+)
+
+// ClippingLimit describes how much of a source was affected by Kindle's clipping limit. After more
+// than 10% of a book has been highlighted, Kindle writes a placeholder instead of the text of each
+// new highlight to its clippings file.
+type ClippingLimit struct {
+	Source string
+
+	// Highlights is the total number of highlights in the source, including placeholders.
+	Highlights int
+
+	// Placeholders is the number of highlights whose text is KindleClippingLimitMessage.
+	Placeholders int
+
+	// TruncatedAt is the location of the first placeholder which was created, i.e. the highlight at
+	// which the clipping limit was reached. TruncatedOn is the time at which it was created.
+	TruncatedAt int
+	TruncatedOn time.Time
+}
+
+// ClippingLimits returns the clipping limit information for every source in the input, sorted by
+// the name of the source.
+func ClippingLimits(input parser.Clippings) []ClippingLimit {
+	limits := make(map[string]*ClippingLimit)
+	for _, clipping := range input {
+		if clipping.Type != parser.ClippingType_Highlight {
+			continue
+		}
+
+		limit, ok := limits[clipping.Source]
+		if !ok {
+			limit = &ClippingLimit{
+				Source: clipping.Source,
+			}
+			limits[clipping.Source] = limit
+		}
+
+		limit.Highlights++
+
+		if !strings.Contains(clipping.Text, parser.KindleClippingLimitMessage) {
+			continue
+		}
+
+		limit.Placeholders++
+		if limit.Placeholders == 1 || clipping.CreateTime.Before(limit.TruncatedOn) {
+			limit.TruncatedAt = clipping.LocationInSource.Start
+			limit.TruncatedOn = clipping.CreateTime
+		}
+	}
+
+	output := make([]ClippingLimit, 0, len(limits))
+	for _, limit := range limits {
+		output = append(output, *limit)
+	}
+
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].Source < output[j].Source
+	})
+
+	return output
+}
@@ -0,0 +1,196 @@
+package clippingsio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the encoding of a file of parsed clippings.
+type Format string
+
+// Format_YAML is a list of clippings. Format_JSON is an array of clippings. Format_JSONL, JSON Lines,
+// has one clipping on each line, so it can be read and written one clipping at a time by tools like
+// jq.
+const (
+	Format_YAML  Format = "yaml"
+	Format_JSON  Format = "json"
+	Format_JSONL Format = "jsonl"
+)
+
+// FormatFlagUsage is the usage of the flags which select the format of a file of clippings.
+const FormatFlagUsage = "yaml, json or jsonl. If empty, the format is chosen by the extension of the file: .json is JSON, .jsonl and .ndjson are JSON Lines, and anything else is YAML. Required if the path is -."
+
+// Stdio is the path which stands for the standard input when clippings are read, and for the
+// standard output when they are written, so that the commands can be chained with pipes, e.g.
+// through jq.
+const Stdio = "-"
+
+// CheckInput returns an error if the path is neither Stdio nor a file which exists.
+func CheckInput(path string) error {
+	if path == Stdio {
+		return nil
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CheckOutput returns an error if the path is not Stdio and a file already exists at the path, so
+// that an existing file is never overwritten.
+func CheckOutput(path string) error {
+	if path == Stdio {
+		return nil
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("'%s' already exists", path)
+	}
+
+	return nil
+}
+
+// DetectFormat returns the format which was given with a flag, or the format of the file with the
+// path if the flag is empty. Files with an unknown extension are YAML, which is the format that
+// this project has always used. The standard input and output do not have an extension, so the
+// flag is required if the path is Stdio.
+func DetectFormat(path string, flagValue string) (Format, error) {
+	switch Format(strings.ToLower(flagValue)) {
+	case Format_YAML:
+		return Format_YAML, nil
+	case Format_JSON:
+		return Format_JSON, nil
+	case Format_JSONL:
+		return Format_JSONL, nil
+	case "":
+		if path == Stdio {
+			return "", fmt.Errorf("format must be given if the path is %s", Stdio)
+		}
+	default:
+		return "", fmt.Errorf("format must be one of yaml, json and jsonl, not '%s'", flagValue)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return Format_JSON, nil
+	case ".jsonl", ".ndjson":
+		return Format_JSONL, nil
+	default:
+		return Format_YAML, nil
+	}
+}
+
+// ReadFile reads the clippings from the file in the format, or from the standard input if the path
+// is Stdio.
+func ReadFile(path string, format Format) (parser.Clippings, error) {
+	if path == Stdio {
+		return Read(os.Stdin, format)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open clippings file > %w", err)
+	}
+	defer file.Close()
+
+	return Read(file, format)
+}
+
+// Read decodes the clippings from the reader in the format.
+func Read(r io.Reader, format Format) (parser.Clippings, error) {
+	var clippings parser.Clippings
+	switch format {
+	case Format_YAML:
+		if err := yaml.NewDecoder(r).Decode(&clippings); err != nil {
+			return nil, fmt.Errorf("could not decode clippings from YAML > %w", err)
+		}
+	case Format_JSON:
+		if err := json.NewDecoder(r).Decode(&clippings); err != nil {
+			return nil, fmt.Errorf("could not decode clippings from JSON > %w", err)
+		}
+	case Format_JSONL:
+		decoder := json.NewDecoder(r)
+		for line := 1; ; line++ {
+			var clipping parser.Clipping
+			err := decoder.Decode(&clipping)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("could not decode clipping %d from JSON Lines > %w", line, err)
+			}
+			clippings = append(clippings, clipping)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format '%s'", format)
+	}
+
+	return clippings, nil
+}
+
+// Write encodes the clippings into the writer in the format.
+func Write(w io.Writer, format Format, clippings parser.Clippings) error {
+	switch format {
+	case Format_YAML:
+		encoder := yaml.NewEncoder(w)
+		if err := encoder.Encode(clippings); err != nil {
+			return fmt.Errorf("could not encode clippings into YAML > %w", err)
+		}
+		if err := encoder.Close(); err != nil {
+			return fmt.Errorf("could not encode clippings into YAML > %w", err)
+		}
+	case Format_JSON:
+		if clippings == nil {
+			clippings = parser.Clippings{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(clippings); err != nil {
+			return fmt.Errorf("could not encode clippings into JSON > %w", err)
+		}
+	case Format_JSONL:
+		// Encode writes a newline after each value, and does not indent it.
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		for _, clipping := range clippings {
+			if err := encoder.Encode(clipping); err != nil {
+				return fmt.Errorf("could not encode clippings into JSON Lines > %w", err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported format '%s'", format)
+	}
+
+	return nil
+}
+
+// WriteFile writes the clippings into a new file in the format, or to the standard output if the
+// path is Stdio.
+func WriteFile(path string, format Format, clippings parser.Clippings) error {
+	if path == Stdio {
+		return Write(os.Stdout, format, clippings)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create clippings file > %w", err)
+	}
+	defer file.Close()
+
+	if err := Write(file, format, clippings); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
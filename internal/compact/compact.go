@@ -0,0 +1,149 @@
+package compact
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+)
+
+// Rules decide which sections of the clippings file are removed from the file on the device. A
+// section is kept if no rule removes it.
+type Rules struct {
+	// Since removes the sections which were created before it. It is ignored if it is zero.
+	Since time.Time
+
+	// Finished removes the sections of books whose source matches it. It is ignored if it is nil.
+	// Sections are always written with the source that they have in the file, so the source which is
+	// matched can be the canonical source of an alias.
+	Finished *regexp.Regexp
+
+	// RemoveClippingLimit removes the highlights whose text is the placeholder that Kindle writes
+	// after the clipping limit of a book has been reached.
+	RemoveClippingLimit bool
+}
+
+// Split returns the sections which are kept and the sections which are removed, in the order of
+// the input.
+func (r Rules) Split(sections []parser.KindleSection) ([]parser.KindleSection, []parser.KindleSection) {
+	var kept, removed []parser.KindleSection
+	for _, section := range sections {
+		if r.removes(section) {
+			removed = append(removed, section)
+		} else {
+			kept = append(kept, section)
+		}
+	}
+
+	return kept, removed
+}
+
+// removes returns true if any rule removes the section.
+func (r Rules) removes(section parser.KindleSection) bool {
+	clipping := section.Clipping
+	if !r.Since.IsZero() && clipping.CreateTime.Before(r.Since) {
+		return true
+	}
+
+	if r.Finished != nil && r.Finished.MatchString(clipping.Source) {
+		return true
+	}
+
+	if r.RemoveClippingLimit && clipping.Type == parser.ClippingType_Highlight &&
+		strings.Contains(clipping.Text, parser.KindleClippingLimitMessage) {
+		return true
+	}
+
+	return false
+}
+
+// Layout is the layout of a clippings file around its sections, which is copied from the original
+// file to the trimmed file.
+type Layout struct {
+	CRLF             bool
+	ByteOrderMark    bool
+	LeadingSeparator bool
+}
+
+// DetectLayout returns the layout of the content of a clippings file.
+func DetectLayout(content []byte) Layout {
+	layout := Layout{
+		CRLF: bytes.Contains(content, []byte("\r\n")),
+	}
+
+	if rest, ok := bytes.CutPrefix(content, []byte("\uFEFF")); ok {
+		layout.ByteOrderMark = true
+		content = rest
+	}
+
+	layout.LeadingSeparator = bytes.HasPrefix(bytes.TrimSpace(content), []byte(parser.KindleClippingsSeparator))
+
+	return layout
+}
+
+// Write writes the bytes of the sections as they were in the original file, each followed by a
+// separator line.
+func Write(w io.Writer, layout Layout, sections []parser.KindleSection) error {
+	newline := "\n"
+	if layout.CRLF {
+		newline = "\r\n"
+	}
+
+	writer := bufio.NewWriter(w)
+
+	if layout.ByteOrderMark {
+		writer.WriteString("\uFEFF")
+	}
+
+	if layout.LeadingSeparator {
+		writer.WriteString(parser.KindleClippingsSeparator + newline)
+	}
+
+	for _, section := range sections {
+		writer.Write(section.Raw)
+		writer.WriteString(parser.KindleClippingsSeparator + newline)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("could not write sections > %w", err)
+	}
+
+	return nil
+}
+
+// Verify returns an error unless every section of the original file is in the archive or in the
+// trimmed file, and the trimmed file only has sections of the original file. Sections are compared
+// by their bytes, and a section which is in the original file more than once must be in the archive
+// and the trimmed file as many times.
+func Verify(original, archive, trimmed []parser.KindleSection) error {
+	available := make(map[string]int)
+	for _, section := range archive {
+		available[string(section.Raw)]++
+	}
+	for _, section := range trimmed {
+		available[string(section.Raw)]++
+	}
+
+	originalCount := make(map[string]int)
+	for i, section := range original {
+		raw := string(section.Raw)
+		originalCount[raw]++
+		if available[raw] == 0 {
+			return fmt.Errorf("section %d from source '%s' is neither in the archive nor in the trimmed file", i, section.Clipping.Source)
+		}
+		available[raw]--
+	}
+
+	for i, section := range trimmed {
+		if originalCount[string(section.Raw)] == 0 {
+			return fmt.Errorf("section %d of the trimmed file from source '%s' is not in the original file", i, section.Clipping.Source)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package compact
+
+import (
+	"testing"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+)
+
+func TestVerify(t *testing.T) {
+	section := func(raw string) parser.KindleSection {
+		return parser.KindleSection{
+			Raw:      []byte(raw),
+			Clipping: parser.Clipping{Source: "Book (Author)"},
+		}
+	}
+	first, second, third := section("first\r\n"), section("second\r\n"), section("third\r\n")
+
+	tests := []struct {
+		name     string
+		original []parser.KindleSection
+		archive  []parser.KindleSection
+		trimmed  []parser.KindleSection
+		wantErr  bool
+	}{
+		{
+			name:     "every section is in the archive or the trimmed file",
+			original: []parser.KindleSection{first, second, third},
+			archive:  []parser.KindleSection{first},
+			trimmed:  []parser.KindleSection{second, third},
+		},
+		{
+			name:     "section is missing",
+			original: []parser.KindleSection{first, second, third},
+			archive:  []parser.KindleSection{first},
+			trimmed:  []parser.KindleSection{third},
+			wantErr:  true,
+		},
+		{
+			name:     "duplicate section is kept only once",
+			original: []parser.KindleSection{first, second, second},
+			archive:  []parser.KindleSection{first},
+			trimmed:  []parser.KindleSection{second},
+			wantErr:  true,
+		},
+		{
+			// The bytes differ only in the line ending, which compact must keep as it is.
+			name:     "section is changed",
+			original: []parser.KindleSection{first, second},
+			archive:  []parser.KindleSection{first},
+			trimmed:  []parser.KindleSection{section("second\n")},
+			wantErr:  true,
+		},
+		{
+			name:     "trimmed file has a section which is not in the original file",
+			original: []parser.KindleSection{first},
+			archive:  []parser.KindleSection{first},
+			trimmed:  []parser.KindleSection{second},
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Verify(test.original, test.archive, test.trimmed)
+			if test.wantErr && err == nil {
+				t.Errorf("verified without an error, want an error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("could not verify: %v", err)
+			}
+		})
+	}
+}
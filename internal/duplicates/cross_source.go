@@ -0,0 +1,195 @@
+package duplicates
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+	"go.uber.org/zap"
+)
+
+// CrossSourcePair is a pair of highlights from two different sources whose text is nearly the
+// same. This happens when the same book is read in two editions, or as an EPUB converted using
+// Calibre and as the version bought from the store.
+type CrossSourcePair struct {
+	First      parser.Clipping
+	Second     parser.Clipping
+	Similarity float64
+}
+
+// CrossSource finds highlights which are near-duplicates of each other but belong to different
+// sources.
+type CrossSource struct {
+	Logger *zap.Logger
+
+	// Threshold is the minimum Jaccard similarity between the shingles of two highlights for them
+	// to be considered duplicates.
+	Threshold float64
+
+	// ShingleSize is the number of words in each shingle.
+	ShingleSize int
+}
+
+// Pairs returns the pairs of near-duplicate highlights, sorted by the source and the location of
+// the first highlight and then of the second highlight, so that the output is the same on every run.
+func (c *CrossSource) Pairs(input parser.Clippings) []CrossSourcePair {
+	var highlights parser.Clippings
+	for _, clipping := range input {
+		if clipping.Type != parser.ClippingType_Highlight {
+			continue
+		}
+
+		// Clipping limit placeholders have the same text in every source, but they are not
+		// duplicates of each other.
+		if strings.Contains(clipping.Text, parser.KindleClippingLimitMessage) {
+			continue
+		}
+
+		highlights = append(highlights, clipping)
+	}
+
+	sort.Sort(highlights)
+
+	shingles := make([]map[string]struct{}, len(highlights))
+	index := make(map[string][]int)
+	for i, highlight := range highlights {
+		shingles[i] = utils.Shingles(highlight.Text, c.ShingleSize)
+		for shingle := range shingles[i] {
+			index[shingle] = append(index[shingle], i)
+		}
+	}
+
+	var output []CrossSourcePair
+	for i := range highlights {
+		// Only highlights which share at least one shingle with this highlight can be similar to
+		// it. Looking at those alone is much faster than comparing every pair of highlights.
+		candidates := make(map[int]bool)
+		for shingle := range shingles[i] {
+			for _, j := range index[shingle] {
+				if j > i && highlights[j].Source != highlights[i].Source {
+					candidates[j] = true
+				}
+			}
+		}
+
+		for j := range candidates {
+			similarity := utils.Jaccard(shingles[i], shingles[j])
+			if similarity < c.Threshold {
+				continue
+			}
+
+			c.Logger.Debug("cross source duplicate found", zap.Any("first", highlights[i]), zap.Any("second", highlights[j]), zap.Float64("similarity", similarity))
+			output = append(output, CrossSourcePair{
+				First:      highlights[i],
+				Second:     highlights[j],
+				Similarity: similarity,
+			})
+		}
+	}
+
+	// The candidates come from a map, so every field which can tell two pairs apart is a sort key.
+	sort.Slice(output, func(i, j int) bool {
+		if lessClipping(output[i].First, output[j].First) || lessClipping(output[j].First, output[i].First) {
+			return lessClipping(output[i].First, output[j].First)
+		}
+		return lessClipping(output[i].Second, output[j].Second)
+	})
+
+	return output
+}
+
+// lessClipping orders clippings by their source, their location, their create time and their text.
+func lessClipping(a, b parser.Clipping) bool {
+	if a.Source != b.Source {
+		return a.Source < b.Source
+	}
+	if a.LocationInSource.Start != b.LocationInSource.Start {
+		return a.LocationInSource.Start < b.LocationInSource.Start
+	}
+	if a.LocationInSource.End != b.LocationInSource.End {
+		return a.LocationInSource.End < b.LocationInSource.End
+	}
+	if !a.CreateTime.Equal(b.CreateTime) {
+		return a.CreateTime.Before(b.CreateTime)
+	}
+	return a.Text < b.Text
+}
+
+// ProposeAliases looks at the sources which share at least minPairs near-duplicate highlights and
+// proposes that they are the same book. The returned aliases go from the variant source to the
+// canonical source. The canonical source is the one with more highlights in the input.
+func ProposeAliases(input parser.Clippings, pairs []CrossSourcePair, minPairs int) parser.SourceAliases {
+	highlightCount := make(map[string]int)
+	for _, clipping := range input {
+		if clipping.Type == parser.ClippingType_Highlight {
+			highlightCount[clipping.Source]++
+		}
+	}
+
+	type sourcePair struct {
+		canonical, variant string
+	}
+
+	pairCount := make(map[sourcePair]int)
+	for _, pair := range pairs {
+		canonical, variant := pair.First.Source, pair.Second.Source
+		if highlightCount[variant] > highlightCount[canonical] ||
+			(highlightCount[variant] == highlightCount[canonical] && variant < canonical) {
+			canonical, variant = variant, canonical
+		}
+		pairCount[sourcePair{canonical, variant}]++
+	}
+
+	var candidates []sourcePair
+	for sp, count := range pairCount {
+		if count >= minPairs {
+			candidates = append(candidates, sp)
+		}
+	}
+
+	// Sources which share the most duplicates are the most likely to be the same book, so they
+	// are given priority when a variant could be merged into more than one canonical source.
+	sort.Slice(candidates, func(i, j int) bool {
+		if pairCount[candidates[i]] != pairCount[candidates[j]] {
+			return pairCount[candidates[i]] > pairCount[candidates[j]]
+		}
+		if candidates[i].canonical != candidates[j].canonical {
+			return candidates[i].canonical < candidates[j].canonical
+		}
+		return candidates[i].variant < candidates[j].variant
+	})
+
+	aliases := make(parser.SourceAliases)
+	for _, sp := range candidates {
+		canonical := sp.canonical
+		for {
+			next, ok := aliases[canonical]
+			if !ok {
+				break
+			}
+			canonical = next
+		}
+
+		if _, ok := aliases[sp.variant]; ok || canonical == sp.variant {
+			continue
+		}
+
+		aliases[sp.variant] = canonical
+	}
+
+	// Resolve chains so that every variant points directly at the final canonical source.
+	for variant := range aliases {
+		canonical := aliases[variant]
+		for {
+			next, ok := aliases[canonical]
+			if !ok {
+				break
+			}
+			canonical = next
+		}
+		aliases[variant] = canonical
+	}
+
+	return aliases
+}
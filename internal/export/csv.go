@@ -0,0 +1,213 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/library"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"go.uber.org/zap"
+)
+
+// csvColumn is a column of a CSV export, with the header of the column and a function which returns
+// the value of the column for an entry of a book.
+type csvColumn struct {
+	header string
+	value  func(c *CSV, book library.Book, entry library.Entry) string
+}
+
+// readwiseColumns are the columns of the CSV files which Readwise imports. Readwise reads tags from
+// the note, where each tag is written with a dot, e.g. ".quote".
+var readwiseColumns = []csvColumn{
+	{"Highlight", func(c *CSV, book library.Book, entry library.Entry) string {
+		return entry.Clipping.Text
+	}},
+	{"Title", func(c *CSV, book library.Book, entry library.Entry) string {
+		return book.Title
+	}},
+	{"Author", func(c *CSV, book library.Book, entry library.Entry) string {
+		return book.Authors
+	}},
+	{"Note", func(c *CSV, book library.Book, entry library.Entry) string {
+		note := c.notes(entry)
+		for _, tag := range entry.Tags {
+			note = strings.TrimSpace(note + " ." + strings.TrimPrefix(tag, "#"))
+		}
+		return note
+	}},
+	{"Location", func(c *CSV, book library.Book, entry library.Entry) string {
+		return fmt.Sprintf("%d", entry.Clipping.LocationInSource.Start)
+	}},
+	{"Location Type", func(c *CSV, book library.Book, entry library.Entry) string {
+		return "location"
+	}},
+	{"Date", func(c *CSV, book library.Book, entry library.Entry) string {
+		if entry.Clipping.CreateTime.IsZero() {
+			return ""
+		}
+		return entry.Clipping.CreateTime.Format("2006-01-02 15:04:05")
+	}},
+}
+
+// customColumns are the columns which can be chosen for a CSV export with custom columns. The header
+// of each column is its name.
+var customColumns = map[string]func(c *CSV, book library.Book, entry library.Entry) string{
+	"source": func(c *CSV, book library.Book, entry library.Entry) string {
+		return book.Source
+	},
+	"title": func(c *CSV, book library.Book, entry library.Entry) string {
+		return book.Title
+	},
+	"authors": func(c *CSV, book library.Book, entry library.Entry) string {
+		return book.Authors
+	},
+	"type": func(c *CSV, book library.Book, entry library.Entry) string {
+		return entry.Clipping.Type.Name()
+	},
+	"text": func(c *CSV, book library.Book, entry library.Entry) string {
+		if entry.Clipping.Type == parser.ClippingType_Note {
+			return c.Markup.Strip(entry.Clipping.Text)
+		}
+		return entry.Clipping.Text
+	},
+	"note": func(c *CSV, book library.Book, entry library.Entry) string {
+		return c.notes(entry)
+	},
+	"tags": func(c *CSV, book library.Book, entry library.Entry) string {
+		return strings.Join(entry.Tags, " ")
+	},
+	"page": func(c *CSV, book library.Book, entry library.Entry) string {
+		return entry.Clipping.Page
+	},
+	"location_start": func(c *CSV, book library.Book, entry library.Entry) string {
+		return fmt.Sprintf("%d", entry.Clipping.LocationInSource.Start)
+	},
+	"location_end": func(c *CSV, book library.Book, entry library.Entry) string {
+		if entry.Clipping.LocationInSource.End == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%d", entry.Clipping.LocationInSource.End)
+	},
+	"created": func(c *CSV, book library.Book, entry library.Entry) string {
+		if entry.Clipping.CreateTime.IsZero() {
+			return ""
+		}
+		return entry.Clipping.CreateTime.Format(time.RFC3339)
+	},
+	"id": func(c *CSV, book library.Book, entry library.Entry) string {
+		return entry.Clipping.ID()
+	},
+	"url": func(c *CSV, book library.Book, entry library.Entry) string {
+		return entry.Clipping.URL
+	},
+}
+
+// CSVColumns returns the names of the columns which can be chosen for a CSV export with custom
+// columns, sorted by name.
+func CSVColumns() []string {
+	names := make([]string, 0, len(customColumns))
+	for name := range customColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// CSV exports the clippings of all the books to a single CSV file. Each row is a highlight, with
+// the notes which are attached to it folded into the row, or a note which is not attached to any
+// highlight. Highlights whose text is KindleClippingLimitMessage are not written.
+type CSV struct {
+	// Columns are the names of the columns of a CSV export with custom columns, from CSVColumns. If
+	// empty, the columns are the ones which Readwise imports: Highlight, Title, Author, Note,
+	// Location, Location Type and Date. Readwise does not import notes without a highlight, so these
+	// are not written.
+	Columns []string
+
+	// Markup has the tags which are removed from the text of notes, and which are written in the tags
+	// of each row instead.
+	Markup markup.Markup
+	Logger *zap.Logger
+}
+
+// Write writes a header row, followed by one row for each entry of each book.
+func (c *CSV) Write(w io.Writer, books []library.Book) error {
+	columns := readwiseColumns
+	if len(c.Columns) > 0 {
+		columns = nil
+		for _, name := range c.Columns {
+			value, ok := customColumns[name]
+			if !ok {
+				return fmt.Errorf("unknown column '%s'; columns must be from: %s", name, strings.Join(CSVColumns(), ", "))
+			}
+			columns = append(columns, csvColumn{header: name, value: value})
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	header := make([]string, 0, len(columns))
+	for _, column := range columns {
+		header = append(header, column.header)
+	}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("could not write CSV header > %w", err)
+	}
+
+	skipped, placeholders := 0, 0
+	for _, book := range books {
+		for _, entry := range book.Entries {
+			// The text of these highlights was not saved because the clipping limit for the book was
+			// reached, so there is nothing to import.
+			if entry.Clipping.Type == parser.ClippingType_Highlight && strings.Contains(entry.Clipping.Text, parser.KindleClippingLimitMessage) {
+				placeholders++
+				continue
+			}
+
+			if len(c.Columns) == 0 && entry.Clipping.Type != parser.ClippingType_Highlight {
+				skipped++
+				continue
+			}
+
+			row := make([]string, 0, len(columns))
+			for _, column := range columns {
+				row = append(row, column.value(c, book, entry))
+			}
+			if err := writer.Write(row); err != nil {
+				return fmt.Errorf("could not write CSV row > %w", err)
+			}
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("could not flush CSV > %w", err)
+	}
+
+	if skipped > 0 {
+		c.Logger.Info("skipped notes which are not attached to any highlight", zap.Int("note_count", skipped))
+	}
+
+	if placeholders > 0 {
+		c.Logger.Info("skipped highlights whose text was not saved because of the clipping limit", zap.Int("highlight_count", placeholders))
+	}
+
+	return nil
+}
+
+// notes returns the text of the notes which are attached to the entry, without their tags, with
+// one note on each line.
+func (c *CSV) notes(entry library.Entry) string {
+	var texts []string
+	for _, note := range entry.Notes {
+		if text := c.Markup.Strip(note.Clipping.Text); text != "" {
+			texts = append(texts, text)
+		}
+	}
+
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,71 @@
+package export
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/library"
+	"go.uber.org/zap"
+)
+
+// Result is the number of files which were written, and the number of files which were not written
+// because their content did not change.
+type Result struct {
+	Written   int
+	Unchanged int
+}
+
+// exportBooks writes one file for each book into the directory. The content of each file is
+// returned by update, given the existing content of the file, which is empty for a new file. Files
+// whose content would not change are not written again.
+func exportBooks(dirPath string, books []library.Book, filename func(library.Book) string, update func(string, library.Book) (string, error), logger *zap.Logger) (Result, error) {
+	var result Result
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return result, fmt.Errorf("could not create output directory > %w", err)
+	}
+
+	filenames := make(map[string]string)
+	for _, book := range books {
+		// Two books can have the same file name, e.g. when their titles differ only in punctuation.
+		// File names are compared without case, because file systems on macOS ignore case.
+		name := filename(book)
+		if other, ok := filenames[strings.ToLower(name)]; ok {
+			logger.Warn("books have the same file name", zap.String("source", book.Source), zap.String("other_source", other))
+			sum := sha256.Sum256([]byte(book.Source))
+			extension := filepath.Ext(name)
+			name = fmt.Sprintf("%s %x%s", strings.TrimSuffix(name, extension), sum[:4], extension)
+		}
+		filenames[strings.ToLower(name)] = book.Source
+
+		filePath := filepath.Join(dirPath, name)
+		existing, err := os.ReadFile(filePath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return result, fmt.Errorf("could not read existing file '%s' > %w", filePath, err)
+		}
+
+		updated, err := update(string(existing), book)
+		if err != nil {
+			return result, fmt.Errorf("could not update file '%s' > %w", filePath, err)
+		}
+
+		if updated == string(existing) {
+			logger.Debug("file is unchanged", zap.String("source", book.Source), zap.String("file", filePath))
+			result.Unchanged++
+			continue
+		}
+
+		if err := os.WriteFile(filePath, []byte(updated), 0644); err != nil {
+			return result, fmt.Errorf("could not write file '%s' > %w", filePath, err)
+		}
+
+		logger.Info("wrote file", zap.String("source", book.Source), zap.String("file", filePath))
+		result.Written++
+	}
+
+	return result, nil
+}
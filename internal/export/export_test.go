@@ -0,0 +1,170 @@
+package export
+
+import (
+	"testing"
+	"time"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/library"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"go.uber.org/zap"
+)
+
+func TestUpdateKeepsUserEdits(t *testing.T) {
+	created := time.Date(2023, 4, 16, 10, 13, 54, 0, time.UTC)
+
+	// The text of the highlight was corrected since the last export, e.g. from Bookcision, which does
+	// not change its ID, and a new highlight was added.
+	clippings := parser.Clippings{
+		{Source: "Anna Karenina (Leo Tolstoy)", Type: parser.ClippingType_Highlight, LocationInSource: parser.Location{Start: 15, End: 17}, CreateTime: created, Text: "Happy families are all alike; every unhappy family is unhappy in its own way."},
+		{Source: "Anna Karenina (Leo Tolstoy)", Type: parser.ClippingType_Note, LocationInSource: parser.Location{Start: 17}, CreateTime: created.Add(time.Minute), Text: "#quote opening line"},
+		{Source: "Anna Karenina (Leo Tolstoy)", Type: parser.ClippingType_Highlight, LocationInSource: parser.Location{Start: 20, End: 21}, CreateTime: created.Add(time.Hour), Text: "Everything was in confusion"},
+	}
+	book := library.Books(clippings, markup.Default())[0]
+
+	logseq := &Logseq{Logger: zap.NewNop()}
+	orgRoam := &OrgRoam{Logger: zap.NewNop()}
+
+	tests := []struct {
+		name     string
+		update   func(string, library.Book) (string, error)
+		existing string
+		want     string
+	}{
+		{
+			name:   "logseq",
+			update: logseq.update,
+			existing: `title:: Anna Karenina
+authors:: Leo Tolstoy
+source:: Anna Karenina (Leo Tolstoy)
+rating:: 5
+
+- Happy families are all alike
+  id:: bb562d61-0e55-5a59-8397-b8f33f857f85
+  location:: 15-17
+  created:: 2023-04-16 10:13
+  favorite:: true
+	- #quote opening line
+	  id:: 09ebf257-ed85-56d3-b17d-0449a1d88369
+	  location:: 17
+	  created:: 2023-04-16 10:14
+	- A thought of my own, with a reference to ((bb562d61-0e55-5a59-8397-b8f33f857f85))
+- A block which I added to the page
+`,
+			want: `title:: Anna Karenina
+authors:: Leo Tolstoy
+source:: Anna Karenina (Leo Tolstoy)
+rating:: 5
+
+- Happy families are all alike; every unhappy family is unhappy in its own way.
+  id:: bb562d61-0e55-5a59-8397-b8f33f857f85
+  location:: 15-17
+  created:: 2023-04-16 10:13
+  favorite:: true
+	- #quote opening line
+	  id:: 09ebf257-ed85-56d3-b17d-0449a1d88369
+	  location:: 17
+	  created:: 2023-04-16 10:14
+	- A thought of my own, with a reference to ((bb562d61-0e55-5a59-8397-b8f33f857f85))
+- A block which I added to the page
+- Everything was in confusion
+  id:: 01842cb2-fb6d-5595-b839-b41e0cb6f071
+  location:: 20-21
+  created:: 2023-04-16 11:13
+`,
+		},
+		{
+			name:   "org-roam",
+			update: orgRoam.update,
+			existing: `:PROPERTIES:
+:ID:       8c641d63-0f4f-5d6b-b382-5418a8ef606a
+:END:
+#+title: Anna Karenina
+#+author: Leo Tolstoy
+#+filetags: :quote:russian:
+
+* TODO [#A] Happy families are all alike                        :favorite:
+:PROPERTIES:
+:ID:       bb562d61-0e55-5a59-8397-b8f33f857f85
+:LOCATION: 15-17
+:CREATED:  [2023-04-16 Sun 10:13]
+:RATING:   5
+:END:
+#+begin_quote
+Happy families are all alike
+#+end_quote
+
+A thought of my own.
+
+** #quote opening line
+:PROPERTIES:
+:ID:       09ebf257-ed85-56d3-b17d-0449a1d88369
+:LOCATION: 17
+:CREATED:  [2023-04-16 Sun 10:14]
+:END:
+
+** A heading which I added
+`,
+			want: `:PROPERTIES:
+:ID:       8c641d63-0f4f-5d6b-b382-5418a8ef606a
+:END:
+#+title: Anna Karenina
+#+author: Leo Tolstoy
+#+filetags: :quote:russian:
+
+* TODO [#A] Happy families are all alike; every unhappy family is unhapp… :favorite:
+:PROPERTIES:
+:ID:       bb562d61-0e55-5a59-8397-b8f33f857f85
+:LOCATION: 15-17
+:CREATED:  [2023-04-16 Sun 10:13]
+:RATING:   5
+:END:
+#+begin_quote
+Happy families are all alike; every unhappy family is unhappy in its own way.
+#+end_quote
+
+A thought of my own.
+
+** #quote opening line
+:PROPERTIES:
+:ID:       09ebf257-ed85-56d3-b17d-0449a1d88369
+:LOCATION: 17
+:CREATED:  [2023-04-16 Sun 10:14]
+:END:
+
+** A heading which I added
+* Everything was in confusion
+:PROPERTIES:
+:ID:       01842cb2-fb6d-5595-b839-b41e0cb6f071
+:LOCATION: 20-21
+:CREATED:  [2023-04-16 Sun 11:13]
+:END:
+#+begin_quote
+Everything was in confusion
+#+end_quote
+
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.update(test.existing, book)
+			if err != nil {
+				t.Fatalf("could not update the existing content: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("updated content is\n%s\nwant\n%s", got, test.want)
+			}
+
+			// Exporting the same clippings again must not change the content.
+			again, err := test.update(got, book)
+			if err != nil {
+				t.Fatalf("could not update the updated content: %v", err)
+			}
+			if again != got {
+				t.Errorf("content changed when it was updated again:\n%s\nwas\n%s", again, got)
+			}
+		})
+	}
+}
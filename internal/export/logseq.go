@@ -0,0 +1,154 @@
+package export
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/library"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"go.uber.org/zap"
+)
+
+// logseqPropertyRx matches a property line of a Logseq page or block, e.g. "id:: 6f1c...".
+var logseqPropertyRx = regexp.MustCompile(`^\s*([^\s:]+)::(\s|$)`)
+
+// Logseq exports each book to a page of a Logseq graph, e.g. the "pages" directory of the graph.
+//
+// Each highlight is a block with an id:: property which is derived from the clipping, so block
+// references to it keep working when the book is exported again. The notes which are attached to a
+// highlight are its child blocks.
+type Logseq struct {
+	DirPath string
+	Logger  *zap.Logger
+}
+
+// Export writes one page for each book. Blocks which are already in a page are found by their id::
+// property and updated in place: their first line and the properties which are set by the export are
+// replaced, and other properties and child blocks are kept. New clippings are added as new blocks.
+// Blocks are never removed.
+func (l *Logseq) Export(books []library.Book) (Result, error) {
+	return exportBooks(l.DirPath, books, func(book library.Book) string {
+		return markdownFilename(book.Title) + ".md"
+	}, l.update, l.Logger)
+}
+
+// update returns the content of the page of the book, given the existing content of the page. The
+// existing content is empty for a new page.
+func (l *Logseq) update(existing string, book library.Book) (string, error) {
+	preamble, blocks := parseSections(splitLines(existing), logseqLevel)
+
+	if len(preamble) == 0 {
+		preamble = []string{""}
+	}
+	preamble = setLogseqProperty(preamble, 0, "", "title", book.Title)
+	preamble = setLogseqProperty(preamble, 0, "", "authors", book.Authors)
+	preamble = setLogseqProperty(preamble, 0, "", "source", book.Source)
+
+	trimBlankLines(blocks)
+	for _, entry := range book.Entries {
+		block := findSection(blocks, hasLogseqID(entry.Clipping.UUID()))
+		if block == nil {
+			block = &section{level: 1}
+			blocks = append(blocks, block)
+		}
+		updateLogseqBlock(block, "", entry.Clipping)
+
+		childIndent := logseqIndent(block) + "\t"
+		for _, note := range entry.Notes {
+			child := findSection(blocks, hasLogseqID(note.Clipping.UUID()))
+			if child == nil {
+				child = &section{level: block.level + 1}
+				block.children = append(block.children, child)
+			}
+			updateLogseqBlock(child, childIndent, note.Clipping)
+		}
+	}
+
+	var builder strings.Builder
+	for _, line := range preamble {
+		builder.WriteString(line)
+		builder.WriteString("\n")
+	}
+	writeSections(&builder, blocks)
+
+	return builder.String(), nil
+}
+
+// updateLogseqBlock sets the first line and the properties of the block from the clipping. A new
+// block, which does not have any lines, is written with the indent.
+func updateLogseqBlock(block *section, indent string, clipping parser.Clipping) {
+	if len(block.lines) == 0 {
+		block.lines = []string{""}
+	} else {
+		indent = logseqIndent(block)
+	}
+
+	block.lines[0] = indent + "- " + singleLine(clipping.Text)
+
+	propertyIndent := indent + "  "
+	block.lines = setLogseqProperty(block.lines, 1, propertyIndent, "id", clipping.UUID())
+	block.lines = setLogseqProperty(block.lines, 1, propertyIndent, "location", location(clipping))
+	if clipping.Page != "" {
+		block.lines = setLogseqProperty(block.lines, 1, propertyIndent, "page", clipping.Page)
+	}
+	if !clipping.CreateTime.IsZero() {
+		block.lines = setLogseqProperty(block.lines, 1, propertyIndent, "created", clipping.CreateTime.Format("2006-01-02 15:04"))
+	}
+}
+
+// setLogseqProperty sets the value of the property in the properties which start at the line with
+// the index start, or adds the property after them.
+func setLogseqProperty(lines []string, start int, indent, key, value string) []string {
+	line := indent + key + ":: " + value
+
+	i := start
+	for ; i < len(lines); i++ {
+		match := logseqPropertyRx.FindStringSubmatch(lines[i])
+		if match == nil {
+			break
+		}
+		if strings.EqualFold(match[1], key) {
+			lines[i] = line
+			return lines
+		}
+	}
+
+	lines = append(lines, "")
+	copy(lines[i+1:], lines[i:])
+	lines[i] = line
+
+	return lines
+}
+
+// logseqLevel returns the level of a line which starts a block, which is one more than the length of
+// its indent.
+func logseqLevel(line string) (int, bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if trimmed != "-" && !strings.HasPrefix(trimmed, "- ") {
+		return 0, false
+	}
+
+	return len(line) - len(trimmed) + 1, true
+}
+
+// logseqIndent returns the indent of the first line of the block.
+func logseqIndent(block *section) string {
+	return block.lines[0][:len(block.lines[0])-len(strings.TrimLeft(block.lines[0], " \t"))]
+}
+
+// hasLogseqID returns a function which matches the block with the id:: property.
+func hasLogseqID(id string) func(*section) bool {
+	return func(block *section) bool {
+		for _, line := range block.lines[1:] {
+			match := logseqPropertyRx.FindStringSubmatch(line)
+			if match == nil {
+				return false
+			}
+			if strings.EqualFold(match[1], "id") && strings.TrimSpace(line[len(match[0]):]) == id {
+				return true
+			}
+		}
+
+		return false
+	}
+}
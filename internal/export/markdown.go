@@ -0,0 +1,266 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/library"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// The clippings of a book are written between these markers. Everything outside them, except the
+// keys of the front matter which are set by the export, is written by the user and is never
+// changed.
+const (
+	MarkdownRegionStart = "<!-- kindle-my-clippings-parser:start -->"
+	MarkdownRegionEnd   = "<!-- kindle-my-clippings-parser:end -->"
+)
+
+// maxMarkdownFilenameLength is the largest number of runes in the name of a Markdown file, without
+// the extension.
+const maxMarkdownFilenameLength = 100
+
+// Markdown exports each book to a separate Markdown file in a directory, e.g. an Obsidian vault.
+type Markdown struct {
+	DirPath string
+
+	// Markup has the tags which are kept as tags in the text of notes.
+	Markup markup.Markup
+	Logger *zap.Logger
+}
+
+// Export writes one file for each book. Existing files are updated: the keys of the front matter
+// which are set by the export are replaced and other keys are kept, and only the text between
+// MarkdownRegionStart and MarkdownRegionEnd is replaced. Files whose content would not change are
+// not written again.
+func (m *Markdown) Export(books []library.Book) (Result, error) {
+	return exportBooks(m.DirPath, books, func(book library.Book) string {
+		return markdownFilename(book.Title) + ".md"
+	}, m.update, m.Logger)
+}
+
+// update returns the content of the file of the book, given the existing content of the file. The
+// existing content is empty for a new file.
+func (m *Markdown) update(existing string, book library.Book) (string, error) {
+	frontMatter, body := splitFrontMatter(existing)
+
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(frontMatter), &document); err != nil {
+		return "", fmt.Errorf("could not decode front matter from YAML > %w", err)
+	}
+	if len(document.Content) == 0 {
+		document = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+	}
+	mapping := document.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return "", errors.New("front matter is not a mapping")
+	}
+
+	setScalar(mapping, "title", "!!str", book.Title)
+	setScalar(mapping, "authors", "!!str", book.Authors)
+	setScalar(mapping, "source", "!!str", book.Source)
+	setScalar(mapping, "highlights", "!!int", fmt.Sprintf("%d", book.Highlights))
+	if !book.FirstHighlight.IsZero() {
+		setScalar(mapping, "first_highlight", "!!timestamp", book.FirstHighlight.Format("2006-01-02"))
+		setScalar(mapping, "last_highlight", "!!timestamp", book.LastHighlight.Format("2006-01-02"))
+	}
+	addTags(mapping, book.Tags)
+
+	var encoded bytes.Buffer
+	encoder := yaml.NewEncoder(&encoded)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return "", fmt.Errorf("could not encode front matter into YAML > %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("could not encode front matter into YAML > %w", err)
+	}
+
+	region := MarkdownRegionStart + "\n\n" + m.render(book) + MarkdownRegionEnd
+	if body == "" {
+		body = fmt.Sprintf("# %s\n\n%s\n", book.Title, region)
+	} else {
+		body = replaceRegion(body, MarkdownRegionStart, MarkdownRegionEnd, region)
+	}
+
+	return "---\n" + encoded.String() + "---\n" + body, nil
+}
+
+// render returns the clippings of the book in Markdown. Each highlight is a blockquote which ends
+// with the block ID of the highlight, followed by the notes that are attached to it. Notes which are
+// not attached to any highlight are paragraphs with their own block ID.
+func (m *Markdown) render(book library.Book) string {
+	var builder strings.Builder
+	for _, entry := range book.Entries {
+		clipping := entry.Clipping
+		switch clipping.Type {
+		case parser.ClippingType_Highlight:
+			for _, line := range strings.Split(utils.EscapeMarkdown(clipping.Text), "\n") {
+				fmt.Fprintf(&builder, "> %s\n", line)
+			}
+			fmt.Fprintf(&builder, "> — %s ^%s\n\n", describe(clipping), clipping.ID())
+
+			for _, note := range entry.Notes {
+				fmt.Fprintf(&builder, "%s\n\n", m.noteText(note.Clipping.Text))
+			}
+		case parser.ClippingType_Note:
+			fmt.Fprintf(&builder, "%s — %s ^%s\n\n", m.noteText(clipping.Text), describe(clipping), clipping.ID())
+		}
+	}
+
+	return builder.String()
+}
+
+// noteText escapes the text of a note, except the tag at its start, so that the tag is a tag in
+// Obsidian as well.
+func (m *Markdown) noteText(text string) string {
+	if tag, _, rest, ok := m.Markup.Match(text); ok {
+		return strings.TrimSpace(tag + " " + utils.EscapeMarkdown(rest))
+	}
+
+	return utils.EscapeMarkdown(text)
+}
+
+// describe returns the page, the location and the create date of the clipping.
+func describe(clipping parser.Clipping) string {
+	var parts []string
+	if clipping.Page != "" {
+		parts = append(parts, "p. "+clipping.Page)
+	}
+
+	location := fmt.Sprintf("loc. %d", clipping.LocationInSource.Start)
+	if clipping.LocationInSource.End > clipping.LocationInSource.Start {
+		location = fmt.Sprintf("loc. %d-%d", clipping.LocationInSource.Start, clipping.LocationInSource.End)
+	}
+	parts = append(parts, location)
+
+	if !clipping.CreateTime.IsZero() {
+		parts = append(parts, clipping.CreateTime.Format("2006-01-02"))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// markdownFilename returns the title without the characters which are not allowed in the names of
+// Obsidian notes.
+func markdownFilename(title string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`*"\/<>:|?#^[]`, r) {
+			return ' '
+		}
+		return r
+	}, title)
+
+	runes := []rune(strings.Join(strings.Fields(cleaned), " "))
+	if len(runes) > maxMarkdownFilenameLength {
+		runes = runes[:maxMarkdownFilenameLength]
+	}
+
+	filename := strings.TrimSpace(string(runes))
+	if filename == "" {
+		return "Untitled"
+	}
+
+	return filename
+}
+
+// splitFrontMatter returns the YAML front matter at the start of the content, without the lines
+// with "---", and the rest of the content.
+func splitFrontMatter(content string) (string, string) {
+	rest, ok := strings.CutPrefix(content, "---\n")
+	if !ok {
+		return "", content
+	}
+
+	if body, ok := strings.CutPrefix(rest, "---\n"); ok {
+		return "", body
+	}
+
+	if frontMatter, body, ok := strings.Cut(rest, "\n---\n"); ok {
+		return frontMatter + "\n", body
+	}
+
+	return "", content
+}
+
+// setScalar sets the value of the key in the mapping, or adds the key at the end of the mapping.
+func setScalar(mapping *yaml.Node, key, tag, value string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+			return
+		}
+	}
+
+	mapping.Content = append(mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value},
+	)
+}
+
+// addTags adds the tags, without the leading #, to the list of tags in the mapping. Tags which were
+// added by the user are kept.
+func addTags(mapping *yaml.Node, tags []string) {
+	var list *yaml.Node
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == "tags" && mapping.Content[i+1].Kind == yaml.SequenceNode {
+			list = mapping.Content[i+1]
+		}
+	}
+
+	if list == nil {
+		if len(tags) == 0 {
+			return
+		}
+
+		// A single tag which is not in a list is replaced with the list.
+		list = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		replaced := false
+		for i := 0; i+1 < len(mapping.Content); i += 2 {
+			if mapping.Content[i].Value == "tags" {
+				if mapping.Content[i+1].Value != "" {
+					list.Content = append(list.Content, mapping.Content[i+1])
+				}
+				mapping.Content[i+1] = list
+				replaced = true
+			}
+		}
+		if !replaced {
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "tags"}, list)
+		}
+	}
+
+	existing := make(map[string]bool)
+	for _, item := range list.Content {
+		existing[item.Value] = true
+	}
+
+	for _, tag := range tags {
+		tag = strings.TrimPrefix(tag, "#")
+		if !existing[tag] {
+			existing[tag] = true
+			list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: tag})
+		}
+	}
+}
+
+// replaceRegion replaces the text from the start marker to the end marker with the region, which
+// includes the markers. If the text does not have the markers, the region is added at the end.
+func replaceRegion(text, startMarker, endMarker, region string) string {
+	start := strings.Index(text, startMarker)
+	end := strings.Index(text, endMarker)
+	if start == -1 || end == -1 || end < start {
+		return strings.TrimRight(text, "\n") + "\n\n" + region + "\n"
+	}
+
+	return text[:start] + region + text[end+len(endMarker):]
+}
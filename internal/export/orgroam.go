@@ -0,0 +1,263 @@
+package export
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/library"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+	"go.uber.org/zap"
+)
+
+// maxOrgHeadingLength is the largest number of runes of the text of a highlight which is used as its
+// heading. The full text is in the quote block under the heading.
+const maxOrgHeadingLength = 60
+
+var (
+	orgHeadingRx  = regexp.MustCompile(`^(\*+)\s`)
+	orgKeywordRx  = regexp.MustCompile(`^\*+\s+((?:TODO|DONE)\s+)?(\[#[A-Z0-9]\]\s+)?`)
+	orgTagsRx     = regexp.MustCompile(`\s+(:[\w@#%:]+:)\s*$`)
+	orgPropertyRx = regexp.MustCompile(`^\s*:([^\s:]+):`)
+)
+
+// OrgRoam exports each book to an org-roam node, which is an Org file with an ID.
+//
+// Each highlight is a heading with an :ID: property which is derived from the clipping, so links to
+// it keep working when the book is exported again. The notes which are attached to a highlight are
+// its subheadings.
+type OrgRoam struct {
+	DirPath string
+	Logger  *zap.Logger
+}
+
+// Export writes one file for each book. Headings which are already in a file are found by their :ID:
+// property and updated in place: their title, the properties which are set by the export and the
+// quote block of a highlight are replaced, and other properties, text and subheadings are kept. New
+// clippings are added as new headings. Headings are never removed.
+func (o *OrgRoam) Export(books []library.Book) (Result, error) {
+	return exportBooks(o.DirPath, books, func(book library.Book) string {
+		return utils.SafeFilename(book.Source) + ".org"
+	}, o.update, o.Logger)
+}
+
+// update returns the content of the file of the book, given the existing content of the file. The
+// existing content is empty for a new file.
+func (o *OrgRoam) update(existing string, book library.Book) (string, error) {
+	preamble, headings := parseSections(splitLines(existing), orgLevel)
+
+	preamble = setOrgFileProperty(preamble, "ID", book.UUID())
+	preamble = setOrgKeyword(preamble, "title", book.Title)
+	if book.Authors != "" {
+		preamble = setOrgKeyword(preamble, "author", book.Authors)
+	}
+	if len(book.Tags) > 0 {
+		preamble = setOrgKeyword(preamble, "filetags", orgFileTags(preamble, book.Tags))
+	}
+	if len(headings) == 0 && strings.TrimSpace(preamble[len(preamble)-1]) != "" {
+		preamble = append(preamble, "")
+	}
+
+	for _, entry := range book.Entries {
+		heading := findSection(headings, hasOrgID(entry.Clipping.UUID()))
+		if heading == nil {
+			heading = &section{level: 1}
+			headings = append(headings, heading)
+		}
+		updateOrgHeading(heading, entry.Clipping)
+
+		for _, note := range entry.Notes {
+			child := findSection(headings, hasOrgID(note.Clipping.UUID()))
+			if child == nil {
+				child = &section{level: heading.level + 1}
+				heading.children = append(heading.children, child)
+			}
+			updateOrgHeading(child, note.Clipping)
+		}
+	}
+
+	var builder strings.Builder
+	for _, line := range preamble {
+		builder.WriteString(line)
+		builder.WriteString("\n")
+	}
+	writeSections(&builder, headings)
+
+	return builder.String(), nil
+}
+
+// updateOrgHeading sets the title, the properties and, for a highlight, the quote block of the
+// heading from the clipping. The level of an existing heading, its TODO keyword, its priority and
+// its tags are kept.
+func updateOrgHeading(heading *section, clipping parser.Clipping) {
+	title := singleLine(clipping.Text)
+	if clipping.Type == parser.ClippingType_Highlight {
+		title = utils.Truncate(clipping.Text, maxOrgHeadingLength)
+	}
+
+	prefix := strings.Repeat("*", heading.level) + " "
+	if len(heading.lines) == 0 {
+		heading.lines = []string{"", ""}
+	} else {
+		if keyword := orgKeywordRx.FindStringSubmatch(heading.lines[0]); keyword != nil {
+			prefix += keyword[1] + keyword[2]
+		}
+		// A title which ends with a word between colons looks like tags, so the tags are only kept if
+		// the heading does not end with the title that the export wrote.
+		line := strings.TrimRight(heading.lines[0], " \t")
+		if tags := orgTagsRx.FindStringSubmatch(line); tags != nil && !strings.HasSuffix(line, " "+title) {
+			title = title + " " + tags[1]
+		}
+	}
+	heading.lines[0] = prefix + title
+
+	heading.lines = setOrgProperty(heading.lines, 1, "ID", clipping.UUID())
+	heading.lines = setOrgProperty(heading.lines, 1, "LOCATION", location(clipping))
+	if clipping.Page != "" {
+		heading.lines = setOrgProperty(heading.lines, 1, "PAGE", clipping.Page)
+	}
+	if !clipping.CreateTime.IsZero() {
+		heading.lines = setOrgProperty(heading.lines, 1, "CREATED", clipping.CreateTime.Format("[2006-01-02 Mon 15:04]"))
+	}
+
+	if clipping.Type == parser.ClippingType_Highlight {
+		quote := append([]string{"#+begin_quote"}, strings.Split(utils.EscapeOrg(clipping.Text), "\n")...)
+		quote = append(quote, "#+end_quote")
+		heading.lines = replaceOrgBlock(heading.lines, quote)
+	}
+}
+
+// setOrgProperty sets the value of the property in the property drawer which starts at the line with
+// the index start. The drawer is added if it does not exist.
+func setOrgProperty(lines []string, start int, key, value string) []string {
+	if start >= len(lines) || !strings.EqualFold(strings.TrimSpace(lines[start]), ":PROPERTIES:") {
+		lines = insertLines(lines, start, ":PROPERTIES:", ":END:")
+	}
+
+	line := fmt.Sprintf("%-10s %s", ":"+key+":", value)
+	i := start + 1
+	for ; i < len(lines); i++ {
+		if strings.EqualFold(strings.TrimSpace(lines[i]), ":END:") {
+			break
+		}
+		if match := orgPropertyRx.FindStringSubmatch(lines[i]); match != nil && strings.EqualFold(match[1], key) {
+			lines[i] = line
+			return lines
+		}
+	}
+
+	return insertLines(lines, i, line)
+}
+
+// setOrgFileProperty sets the value of the property in the property drawer at the start of the file,
+// before any keywords.
+func setOrgFileProperty(preamble []string, key, value string) []string {
+	if len(preamble) == 0 {
+		preamble = []string{""}
+	}
+
+	return setOrgProperty(preamble, 0, key, value)
+}
+
+// setOrgKeyword sets the value of the keyword, e.g. "#+title:", or adds the keyword after the last
+// keyword or after the property drawer at the start of the file.
+func setOrgKeyword(preamble []string, keyword, value string) []string {
+	line := "#+" + keyword + ": " + value
+
+	after := 0
+	for i, existing := range preamble {
+		trimmed := strings.TrimSpace(existing)
+		if strings.HasPrefix(strings.ToLower(trimmed), "#+"+keyword+":") {
+			preamble[i] = line
+			return preamble
+		}
+		if strings.HasPrefix(trimmed, "#+") || strings.EqualFold(trimmed, ":END:") {
+			after = i + 1
+		}
+	}
+
+	return insertLines(preamble, after, line)
+}
+
+// orgFileTags returns the tags of the "#+filetags:" keyword with the tags of the book added, e.g.
+// ":quote:cn:". Tags which were added by the user are kept.
+func orgFileTags(preamble []string, tags []string) string {
+	var existing []string
+	for _, line := range preamble {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(strings.ToLower(trimmed), "#+filetags:") {
+			existing = strings.FieldsFunc(trimmed[len("#+filetags:"):], func(r rune) bool {
+				return r == ':' || r == ' '
+			})
+		}
+	}
+
+	return utils.OrgTags(append(existing, tags...))
+}
+
+// replaceOrgBlock replaces the first quote block in the lines of a heading with the block, or adds
+// the block after the property drawer.
+func replaceOrgBlock(lines []string, block []string) []string {
+	start, end := -1, -1
+	for i, line := range lines {
+		trimmed := strings.ToLower(strings.TrimSpace(line))
+		if start == -1 && trimmed == "#+begin_quote" {
+			start = i
+		}
+		if start != -1 && trimmed == "#+end_quote" {
+			end = i
+			break
+		}
+	}
+
+	if start != -1 && end != -1 {
+		replaced := append([]string{}, lines[:start]...)
+		replaced = append(replaced, block...)
+		return append(replaced, lines[end+1:]...)
+	}
+
+	after := 1
+	for i, line := range lines {
+		if strings.EqualFold(strings.TrimSpace(line), ":END:") {
+			after = i + 1
+			break
+		}
+	}
+
+	return insertLines(lines, after, block...)
+}
+
+// insertLines inserts the new lines before the line with the index i.
+func insertLines(lines []string, i int, newLines ...string) []string {
+	inserted := append([]string{}, lines[:i]...)
+	inserted = append(inserted, newLines...)
+	return append(inserted, lines[i:]...)
+}
+
+// orgLevel returns the level of a line which starts a heading, which is the number of stars.
+func orgLevel(line string) (int, bool) {
+	match := orgHeadingRx.FindStringSubmatch(line)
+	if match == nil {
+		return 0, false
+	}
+
+	return len(match[1]), true
+}
+
+// hasOrgID returns a function which matches the heading with the :ID: property.
+func hasOrgID(id string) func(*section) bool {
+	return func(heading *section) bool {
+		for _, line := range heading.lines[1:] {
+			trimmed := strings.TrimSpace(line)
+			if strings.EqualFold(trimmed, ":END:") {
+				return false
+			}
+			if match := orgPropertyRx.FindStringSubmatch(trimmed); match != nil && strings.EqualFold(match[1], "ID") {
+				return strings.TrimSpace(trimmed[len(match[0]):]) == id
+			}
+		}
+
+		return false
+	}
+}
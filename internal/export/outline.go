@@ -0,0 +1,116 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+)
+
+// location returns the location of the clipping as "start" or "start-end".
+func location(clipping parser.Clipping) string {
+	if clipping.LocationInSource.End > clipping.LocationInSource.Start {
+		return fmt.Sprintf("%d-%d", clipping.LocationInSource.Start, clipping.LocationInSource.End)
+	}
+
+	return fmt.Sprintf("%d", clipping.LocationInSource.Start)
+}
+
+// singleLine joins the lines of the text with spaces. Outline blocks and headings are a single line.
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// section is a block of a Logseq page or a heading of an Org file, with the lines which belong to it
+// before its first child. Lines are kept as they are, so that the text which the user added to a
+// section is written back unchanged.
+type section struct {
+	level    int
+	lines    []string
+	children []*section
+}
+
+// parseSections splits the lines of a file into the lines before the first section and a tree of
+// sections. levelOf returns the level of a line which starts a section.
+func parseSections(lines []string, levelOf func(string) (int, bool)) ([]string, []*section) {
+	var preamble []string
+	var roots []*section
+	var stack []*section
+	for _, line := range lines {
+		level, ok := levelOf(line)
+		if !ok {
+			if len(stack) == 0 {
+				preamble = append(preamble, line)
+			} else {
+				top := stack[len(stack)-1]
+				top.lines = append(top.lines, line)
+			}
+			continue
+		}
+
+		s := &section{level: level, lines: []string{line}}
+		for len(stack) > 0 && stack[len(stack)-1].level >= level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, s)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, s)
+		}
+		stack = append(stack, s)
+	}
+
+	return preamble, roots
+}
+
+// writeSections writes the lines of the sections and their children, in order.
+func writeSections(builder *strings.Builder, sections []*section) {
+	for _, s := range sections {
+		for _, line := range s.lines {
+			builder.WriteString(line)
+			builder.WriteString("\n")
+		}
+		writeSections(builder, s.children)
+	}
+}
+
+// findSection returns the first section in the tree for which match is true, or nil.
+func findSection(sections []*section, match func(*section) bool) *section {
+	for _, s := range sections {
+		if match(s) {
+			return s
+		}
+		if found := findSection(s.children, match); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// splitLines returns the lines of the content, without the newline at the end of the last line.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// trimBlankLines removes the blank lines at the end of the last section, so that sections which are
+// added at the end follow it directly.
+func trimBlankLines(sections []*section) {
+	if len(sections) == 0 {
+		return
+	}
+
+	last := sections[len(sections)-1]
+	if len(last.children) > 0 {
+		trimBlankLines(last.children)
+		return
+	}
+	for len(last.lines) > 1 && strings.TrimSpace(last.lines[len(last.lines)-1]) == "" {
+		last.lines = last.lines[:len(last.lines)-1]
+	}
+}
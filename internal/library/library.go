@@ -0,0 +1,130 @@
+package library
+
+import (
+	"sort"
+	"time"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+)
+
+// Book is a single source and all its clippings.
+type Book struct {
+	Source string
+
+	// Title and Authors are split from the source, which Kindle writes as "Title (Authors)". If the
+	// source does not have the authors, the authors from Bookcision are used, if there are any.
+	Title   string
+	Authors string
+
+	// Highlights and Notes are the number of clippings of each type.
+	Highlights int
+	Notes      int
+
+	// FirstHighlight and LastHighlight are the create times of the oldest and the newest highlight.
+	// They are zero if the book does not have any highlights.
+	FirstHighlight time.Time
+	LastHighlight  time.Time
+
+	// Tags are the sorted tags which are used in the notes of the book, e.g. "#quote".
+	Tags []string
+
+	// Clippings are sorted by location.
+	Clippings parser.Clippings
+
+	// Entries are the clippings in the same order, with each note nested under the highlight that it
+	// is attached to.
+	Entries []Entry
+}
+
+// UUID returns an ID of the book which does not change when the clippings file is parsed again. It
+// is derived from the source in Kindle's file of the oldest clipping of the book, so that adding a
+// source alias for the book does not change it.
+func (b Book) UUID() string {
+	source := b.Source
+	var oldest time.Time
+	for _, clipping := range b.Clippings {
+		if clipping.CreateTime.IsZero() || (!oldest.IsZero() && !clipping.CreateTime.Before(oldest)) {
+			continue
+		}
+
+		oldest = clipping.CreateTime
+		source = clipping.Source
+		if clipping.OriginalSource != "" {
+			source = clipping.OriginalSource
+		}
+	}
+
+	return parser.NameUUID("source:" + source)
+}
+
+// Books returns the books in the input, sorted by source. The tags of each book are the tags of the
+// vocabulary which are used at the start of its notes.
+func Books(input parser.Clippings, vocabulary markup.Markup) []Book {
+	clippings := make(parser.Clippings, len(input))
+	copy(clippings, input)
+	sort.Sort(clippings)
+
+	var books []Book
+	var tags map[string]bool
+	for _, clipping := range clippings {
+		// Clippings are sorted by source, so all the clippings of a book are next to each other.
+		if len(books) == 0 || books[len(books)-1].Source != clipping.Source {
+			if len(books) > 0 {
+				books[len(books)-1].Tags = sortedKeys(tags)
+			}
+
+			title, authors := utils.SplitSource(clipping.Source)
+			books = append(books, Book{
+				Source:  clipping.Source,
+				Title:   title,
+				Authors: authors,
+			})
+			tags = make(map[string]bool)
+		}
+
+		book := &books[len(books)-1]
+		book.Clippings = append(book.Clippings, clipping)
+
+		if book.Authors == "" {
+			book.Authors = clipping.Authors
+		}
+
+		switch clipping.Type {
+		case parser.ClippingType_Highlight:
+			book.Highlights++
+			if book.FirstHighlight.IsZero() || clipping.CreateTime.Before(book.FirstHighlight) {
+				book.FirstHighlight = clipping.CreateTime
+			}
+			if clipping.CreateTime.After(book.LastHighlight) {
+				book.LastHighlight = clipping.CreateTime
+			}
+		case parser.ClippingType_Note:
+			book.Notes++
+			if tag, _, _, ok := vocabulary.Match(clipping.Text); ok {
+				tags[tag] = true
+			}
+		}
+	}
+
+	if len(books) > 0 {
+		books[len(books)-1].Tags = sortedKeys(tags)
+	}
+
+	for i := range books {
+		books[i].Entries = Outline(books[i].Clippings, vocabulary)
+	}
+
+	return books
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,62 @@
+package library
+
+import (
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+)
+
+// Entry is a highlight, or a note which is not attached to a highlight, with the notes which are
+// attached to it.
+type Entry struct {
+	Clipping parser.Clipping
+
+	// Tags are the sorted tags at the start of the note, or at the start of the notes which are
+	// attached to the highlight.
+	Tags []string
+
+	// Notes are the entries of the notes which are attached to the highlight. They do not have
+	// notes of their own.
+	Notes []Entry
+}
+
+// Outline returns the clippings of a book as a list of entries, in the order in which they appear in
+// the book. Kindle writes the same clipping twice when it is synced twice; IDs must be unique in a
+// file, so copies of a clipping with the same ID are dropped.
+func Outline(clippings parser.Clippings, vocabulary markup.Markup) []Entry {
+	notes := make(map[int][]int)
+	attached := make(map[int]bool)
+	for i := range clippings {
+		if j, ok := clippings.HighlightForNote(i); ok {
+			notes[j] = append(notes[j], i)
+			attached[i] = true
+		}
+	}
+
+	var entries []Entry
+	seen := make(map[string]bool)
+	for i, clipping := range clippings {
+		if attached[i] || seen[clipping.ID()] {
+			continue
+		}
+		seen[clipping.ID()] = true
+
+		entry := Entry{Clipping: clipping}
+		tags := make(map[string]bool)
+		if tag, _, _, ok := vocabulary.Match(clipping.Text); ok && clipping.Type == parser.ClippingType_Note {
+			tags[tag] = true
+		}
+		for _, j := range notes[i] {
+			note := Entry{Clipping: clippings[j]}
+			if tag, _, _, ok := vocabulary.Match(clippings[j].Text); ok {
+				note.Tags = []string{tag}
+				tags[tag] = true
+			}
+			entry.Notes = append(entry.Notes, note)
+		}
+		entry.Tags = sortedKeys(tags)
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
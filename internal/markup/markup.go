@@ -0,0 +1,222 @@
+package markup
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// Role is what a note which starts with a tag means to the commands in this project.
+type Role string
+
+const (
+	// Role_Quote marks the highlight that the note is attached to as a quote.
+	Role_Quote Role = "quote"
+
+	// Role_ChapterName marks the highlight that the note is attached to as the name of a chapter.
+	// The note can have the level of the chapter and the name of the chapter after the tag.
+	Role_ChapterName Role = "chapter-name"
+
+	// Role_ChapterSummary marks the text of the note after the tag as the summary of the current
+	// chapter.
+	Role_ChapterSummary Role = "chapter-summary"
+
+	// Role_ReadingList marks a reference to a book or a web page that I want to read.
+	Role_ReadingList Role = "reading-list"
+
+	// Role_Custom is a tag which is not used by any command. Custom tags are recognized, so that
+	// templates can strip them or use them for grouping.
+	Role_Custom Role = "custom"
+)
+
+// Markup maps tags, including the leading #, to their roles. More than one tag can have the same
+// role.
+type Markup map[string]Role
+
+// MarkupFile is the structure of the markup file:
+//
+// --- Sample START ---
+//
+// tags:
+//
+//	q: quote
+//	ch: chapter-name
+//	sum: chapter-summary
+//	toread: reading-list
+//	idea: custom
+//
+// --- Sample END ---
+//
+// The leading # of each tag is optional in the file.
+type MarkupFile struct {
+	Tags Markup `yaml:"tags"`
+}
+
+// Default returns the tags which are described in the README of this project.
+func Default() Markup {
+	return Markup{
+		"#quote": Role_Quote,
+		"#cn":    Role_ChapterName,
+		"#cs":    Role_ChapterSummary,
+		"#read":  Role_ReadingList,
+	}
+}
+
+// ReadMarkup reads the markup file at the given path. The tags in the file replace the default
+// tags. An empty path is not an error; it returns the default tags.
+func ReadMarkup(filePath string) (Markup, error) {
+	if filePath == "" {
+		return Default(), nil
+	}
+
+	markupFile, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the markup file > %w", err)
+	}
+	defer markupFile.Close()
+
+	var contents MarkupFile
+	if err := yaml.NewDecoder(markupFile).Decode(&contents); err != nil {
+		return nil, fmt.Errorf("could not decode markup file from YAML > %w", err)
+	}
+
+	output := make(Markup)
+	for tag, role := range contents.Tags {
+		switch role {
+		case Role_Quote, Role_ChapterName, Role_ChapterSummary, Role_ReadingList, Role_Custom:
+		default:
+			return nil, fmt.Errorf("tag '%s' has an unknown role '%s'", tag, role)
+		}
+
+		if !strings.HasPrefix(tag, "#") {
+			tag = "#" + tag
+		}
+
+		if tag == "#" || strings.ContainsAny(tag, " \t\n") {
+			return nil, fmt.Errorf("tag '%s' must be a single word", tag)
+		}
+
+		output[tag] = role
+	}
+
+	if len(output) == 0 {
+		return nil, fmt.Errorf("markup file '%s' does not have any tags", filePath)
+	}
+
+	return output, nil
+}
+
+// Match returns the tag at the start of the text, its role and the rest of the text without the
+// tag. The tag must be followed by a space or nothing at all, so "#cs" does not match "#csv".
+func (m Markup) Match(text string) (tag string, role Role, rest string, ok bool) {
+	for tag, role := range m {
+		rest, ok := strings.CutPrefix(text, tag)
+		if !ok {
+			continue
+		}
+
+		if rest != "" && !strings.HasPrefix(rest, " ") {
+			continue
+		}
+
+		return tag, role, strings.TrimSpace(rest), true
+	}
+
+	return "", "", "", false
+}
+
+// Is returns the text without the tag and true, if the text starts with a tag of the given role.
+func (m Markup) Is(text string, role Role) (string, bool) {
+	_, matchedRole, rest, ok := m.Match(text)
+	if !ok || matchedRole != role {
+		return "", false
+	}
+
+	return rest, true
+}
+
+// ChapterName returns the level and the name of the chapter and true, if the text starts with a tag
+// of the chapter name role. The text after the tag can start with the level of the chapter, e.g.
+// "#cn 2 The Beginning"; chapters without a level are at level 1. The name is empty if the text
+// only has the tag and the level.
+func (m Markup) ChapterName(text string) (int, string, bool) {
+	arguments, ok := m.Is(text, Role_ChapterName)
+	if !ok {
+		return 0, "", false
+	}
+
+	levelArgument, name, _ := strings.Cut(arguments, " ")
+	if level, err := strconv.Atoi(levelArgument); err == nil && level > 0 {
+		return level, strings.TrimSpace(name), true
+	}
+
+	return 1, arguments, true
+}
+
+// Strip returns the text without the tag at its start. Text which does not start with a tag is
+// returned unchanged.
+func (m Markup) Strip(text string) string {
+	if _, _, rest, ok := m.Match(text); ok {
+		return rest
+	}
+
+	return text
+}
+
+// Tags returns the sorted tags of the given role.
+func (m Markup) Tags(role Role) []string {
+	var tags []string
+	for tag, tagRole := range m {
+		if tagRole == role {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+
+	return tags
+}
+
+// HasRole returns true if any note in the input starts with a tag of one of the given roles.
+func (m Markup) HasRole(input parser.Clippings, roles ...Role) bool {
+	for _, clipping := range input {
+		if clipping.Type != parser.ClippingType_Note {
+			continue
+		}
+
+		for _, role := range roles {
+			if _, ok := m.Is(clipping.Text, role); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// FuncMap returns the functions which templates can use to work with the tags in the text of a
+// clipping:
+//
+//   - stripTag: the text without its tag
+//   - tagOf: the tag at the start of the text, or an empty string
+//   - roleOf: the role of the tag at the start of the text, or an empty string
+//
+// The map can be used with both text/template and html/template.
+func (m Markup) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"stripTag": m.Strip,
+		"tagOf": func(text string) string {
+			tag, _, _, _ := m.Match(text)
+			return tag
+		},
+		"roleOf": func(text string) string {
+			_, role, _, _ := m.Match(text)
+			return string(role)
+		},
+	}
+}
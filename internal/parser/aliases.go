@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SourceAliases maps variant source strings to the canonical source string of the same book.
+//
+// Editing the metadata of a book in Calibre changes the first line that Kindle writes for each
+// clipping. So, the same book can appear as "Anna Karenina (Tolstoy, Leo)" and "Anna Karenina (Leo
+// Tolstoy)".
+type SourceAliases map[string]string
+
+// SourceAliasesFile is the structure of the aliases file:
+//
+// --- Sample START ---
+//
+// aliases:
+//
+//	Anna Karenina (Leo Tolstoy): Anna Karenina (Tolstoy, Leo)
+//
+// --- Sample END ---
+//
+// The report that is output by the cmd/cross-source-duplicates command has the same structure and
+// can be used as an aliases file as well.
+type SourceAliasesFile struct {
+	Aliases SourceAliases `yaml:"aliases"`
+}
+
+// ReadSourceAliases reads the aliases file at the given path. An empty path is not an error; it
+// returns an empty set of aliases.
+func ReadSourceAliases(filePath string) (SourceAliases, error) {
+	if filePath == "" {
+		return SourceAliases{}, nil
+	}
+
+	aliasesFile, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open the aliases file > %w", err)
+	}
+	defer aliasesFile.Close()
+
+	var contents SourceAliasesFile
+	if err := yaml.NewDecoder(aliasesFile).Decode(&contents); err != nil {
+		return nil, fmt.Errorf("could not decode aliases file from YAML > %w", err)
+	}
+
+	for variant, canonical := range contents.Aliases {
+		if _, ok := contents.Aliases[canonical]; ok {
+			return nil, fmt.Errorf("canonical source '%s' of variant '%s' is a variant itself", canonical, variant)
+		}
+	}
+
+	return contents.Aliases, nil
+}
+
+// Canonical returns the canonical source for the given source. Sources which are not in the
+// aliases map are returned as-is.
+func (s SourceAliases) Canonical(source string) string {
+	if canonical, ok := s[source]; ok {
+		return canonical
+	}
+
+	return source
+}
+
+// Apply replaces the source of every clipping with its canonical source. The source which is
+// replaced is kept as the original source of the clipping, unless the clipping already has one.
+func (s SourceAliases) Apply(input Clippings) Clippings {
+	output := make(Clippings, 0, len(input))
+	for _, clipping := range input {
+		clipping.setSource(s.Canonical(clipping.Source))
+		output = append(output, clipping)
+	}
+
+	return output
+}
@@ -33,43 +33,74 @@ type BookcisionHighlightLocation struct {
 
 // Parse ...
 func (b *BookcisionClippings) Parse() (Clippings, error) {
+	_, output, err := b.ParseSet()
+	return output, err
+}
+
+// ParseSet returns the clippings along with the set that was read from the JSON file. The set has
+// the title and the authors of the book, which are not a part of each clipping.
+func (b *BookcisionClippings) ParseSet() (BookcisionClippingSet, Clippings, error) {
 	supplementFile, err := os.Open(b.FilePath)
 	if err != nil {
-		return nil, fmt.Errorf("could not read input JSON file for supplements > %w", err)
+		return BookcisionClippingSet{}, nil, fmt.Errorf("could not read input JSON file for supplements > %w", err)
 	}
 	defer supplementFile.Close()
 
 	readerSupplement := json.NewDecoder(supplementFile)
 	supplemented := BookcisionClippingSet{}
 	if err := readerSupplement.Decode(&supplemented); err != nil {
-		return nil, fmt.Errorf("could not encode clippings from bookcision JSON file > %w", err)
+		return BookcisionClippingSet{}, nil, fmt.Errorf("could not encode clippings from bookcision JSON file > %w", err)
+	}
+
+	output, err := b.convertToParser(supplemented)
+	if err != nil {
+		return BookcisionClippingSet{}, nil, err
 	}
 
-	return b.convertToParser(supplemented)
+	return supplemented, output, nil
 }
 
 // convertToParser ...
 func (b *BookcisionClippings) convertToParser(in BookcisionClippingSet) (Clippings, error) {
 	var output Clippings
-	for _, hl := range in.Highlights {
-		output = append(output, Clipping{
+	for i, hl := range in.Highlights {
+		// The note of a highlight is in the same entry as the highlight, which is the only place
+		// where they are linked, so both get the index of the entry.
+		clipping := Clipping{
 			Source: in.Title,
-			Type:   ClippingType_Highlight,
 			LocationInSource: Location{
 				Start: hl.Location.Value,
 			},
-			Text: hl.Text,
-		})
+			Authors:         in.Authors,
+			URL:             hl.Location.URL,
+			Origin:          Origin_Bookcision,
+			BookcisionIndex: i + 1,
+		}
+
+		// Notes which are not attached to a highlight are standalone notes. Bookcision usually puts
+		// the text of these notes in the note field, so the text field is used only if that is
+		// empty.
+		if hl.IsNoteOnly {
+			clipping.Type = ClippingType_Note
+			clipping.NoteOnly = true
+			clipping.Text = hl.Note
+			if clipping.Text == "" {
+				clipping.Text = hl.Text
+			}
+			output = append(output, clipping)
+			continue
+		}
+
+		highlight := clipping
+		highlight.Type = ClippingType_Highlight
+		highlight.Text = hl.Text
+		output = append(output, highlight)
 
 		if hl.Note != "" {
-			output = append(output, Clipping{
-				Source: in.Title,
-				Type:   ClippingType_Note,
-				LocationInSource: Location{
-					Start: hl.Location.Value,
-				},
-				Text: hl.Note,
-			})
+			note := clipping
+			note.Type = ClippingType_Note
+			note.Text = hl.Note
+			output = append(output, note)
 		}
 	}
 
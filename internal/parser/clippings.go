@@ -1,13 +1,16 @@
 package parser
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"strings"
 	"time"
 )
 
 type Location struct {
-	Start int `yaml:",omitempty"`
-	End   int `yaml:",omitempty"`
+	Start int `yaml:",omitempty" json:"start,omitempty"`
+	End   int `yaml:",omitempty" json:"end,omitempty"`
 }
 
 type ClippingType int
@@ -16,18 +19,120 @@ const (
 	ClippingType_None ClippingType = iota
 	ClippingType_Highlight
 	ClippingType_Note
+	ClippingType_Bookmark
+)
+
+// Name returns the name of the type, e.g. "highlight", or an empty string for ClippingType_None.
+func (t ClippingType) Name() string {
+	switch t {
+	case ClippingType_Highlight:
+		return "highlight"
+	case ClippingType_Note:
+		return "note"
+	case ClippingType_Bookmark:
+		return "bookmark"
+	default:
+		return ""
+	}
+}
+
+// Origin is the place from which a clipping was read. Clippings which were read from Kindle's
+// clippings file have an empty origin, so that the YAML output for them is unchanged.
+type Origin string
+
+const (
+	Origin_Kindle     Origin = ""
+	Origin_Bookcision Origin = "bookcision"
 )
 
 type Clipping struct {
-	Source string       `yaml:"source"`
-	Type   ClippingType `yaml:"type"`
+	Source string       `yaml:"source" json:"source"`
+	Type   ClippingType `yaml:"type" json:"type"`
 
 	// Page is not always a number. Sometimes it is a lowercase Roman numeral "ix"
-	Page string `yaml:"page"`
+	Page string `yaml:"page" json:"page"`
+
+	LocationInSource Location  `yaml:"location_in_source" json:"location_in_source"`
+	CreateTime       time.Time `yaml:"create_time" json:"create_time"`
+	Text             string    `yaml:"text" json:"text"`
+
+	// RawText is the text of the clipping with the whitespace around it, as it is in Kindle's
+	// clippings file. It is only set if it is not the same as Text, and is only read by KindleWriter,
+	// so it is not written to the output.
+	RawText string `yaml:"-" json:"-"`
+
+	// Authors of the book. Kindle's clippings file has the authors only as a part of the source, so
+	// this is set only for clippings which were supplemented from Bookcision.
+	Authors string `yaml:"authors,omitempty" json:"authors,omitempty"`
+
+	// URL is a kindle:// link which opens the book at the location of this clipping.
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// NoteOnly is set for notes which Bookcision marks as note-only. These notes are not attached to
+	// any highlight, even if they are at the location of one.
+	NoteOnly bool `yaml:"note_only,omitempty" json:"note_only,omitempty"`
+
+	// BookcisionIndex is the position, starting at 1, of the entry in the Bookcision export that the
+	// clipping was read from. A highlight and the note which Bookcision attached to it have the same
+	// index, so that they can be paired after the clippings are sorted. It is zero for clippings from
+	// other origins, and it is not written to the output.
+	BookcisionIndex int `yaml:"-" json:"-"`
+
+	Origin Origin `yaml:"origin,omitempty" json:"origin,omitempty"`
+
+	// OriginalSource is the source of the clipping in Kindle's clippings file, if the source was
+	// replaced with its canonical source by a source alias. It is empty if the source was never
+	// replaced.
+	OriginalSource string `yaml:"original_source,omitempty" json:"original_source,omitempty"`
+
+	// Variant is the format of the description line of the clipping in Kindle's clippings file, so
+	// that the clipping can be written back in the same format. It is nil for clippings from other
+	// origins.
+	Variant *Variant `yaml:"variant,omitempty" json:"variant,omitempty"`
+}
+
+// ID returns an identifier of the clipping which does not change when the clippings file is parsed
+// again. It is derived from the source, the type, the location and the create time of the clipping,
+// but not from its text, so that correcting a highlight from Bookcision does not change its ID. The
+// source is the one in Kindle's clippings file, i.e. the original source if a source alias replaced
+// it, so that adding an alias for a book does not change the IDs of its clippings.
+func (c Clipping) ID() string {
+	source := c.Source
+	if c.OriginalSource != "" {
+		source = c.OriginalSource
+	}
 
-	LocationInSource Location  `yaml:"location_in_source"`
-	CreateTime       time.Time `yaml:"create_time"`
-	Text             string    `yaml:"text"`
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d\x00%d\x00%s", source, c.Type, c.LocationInSource.Start, c.LocationInSource.End, c.CreateTime.UTC().Format(time.RFC3339))))
+	return hex.EncodeToString(sum[:8])
+}
+
+// setSource replaces the source of the clipping, and keeps the source that is replaced as the
+// original source if the clipping does not have one yet.
+func (c *Clipping) setSource(source string) {
+	if source == c.Source {
+		return
+	}
+
+	if c.OriginalSource == "" {
+		c.OriginalSource = c.Source
+	}
+	c.Source = source
+}
+
+// UUID returns the ID of the clipping as a UUID, for the outliners and Org mode, which expect IDs to
+// be UUIDs.
+func (c Clipping) UUID() string {
+	return NameUUID("clipping:" + c.ID())
+}
+
+// NameUUID returns a UUID which is derived from the name, in the same way as a version 5 UUID but
+// with SHA-256. The same name always gives the same UUID.
+func NameUUID(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
 }
 
 type Clippings []Clipping
@@ -63,3 +168,42 @@ func (c Clippings) Less(i, j int) bool {
 func (c Clippings) Swap(i, j int) {
 	c[i], c[j] = c[j], c[i]
 }
+
+// HighlightForNote returns the index of the highlight to which the note at index i is attached. Kindle
+// writes a note at the end location of the highlight that it is attached to, so the highlight is the
+// one from the same source whose location range contains the location of the note. If more than one
+// highlight contains the note, then the one which ends closest to the note is chosen. The second
+// return value is false if the clipping at index i is not a note, if it is a note-only note, or if
+// no highlight contains it.
+func (c Clippings) HighlightForNote(i int) (int, bool) {
+	note := c[i]
+	if note.Type != ClippingType_Note || note.NoteOnly {
+		return 0, false
+	}
+
+	found, distance := -1, 0
+	for j, highlight := range c {
+		if highlight.Type != ClippingType_Highlight || highlight.Source != note.Source {
+			continue
+		}
+
+		end := highlight.LocationInSource.End
+		if end < highlight.LocationInSource.Start {
+			end = highlight.LocationInSource.Start
+		}
+
+		if note.LocationInSource.Start < highlight.LocationInSource.Start || note.LocationInSource.Start > end {
+			continue
+		}
+
+		// Prefer the highlight which ends closest to the note. Between highlights which end at the
+		// same location, prefer the most recent one, because Kindle adds a new entry every time an
+		// existing highlight is changed.
+		if found == -1 || end-note.LocationInSource.Start < distance ||
+			(end-note.LocationInSource.Start == distance && highlight.CreateTime.After(c[found].CreateTime)) {
+			found, distance = j, end-note.LocationInSource.Start
+		}
+	}
+
+	return found, found != -1
+}
@@ -17,7 +17,13 @@ import (
 type KindleClippings struct {
 	FilePath                     string
 	RemoveClippingLimitClippings bool
-	logger                       *zap.Logger
+	Aliases                      SourceAliases
+
+	// KeepBookmarks returns bookmarks from Parse as clippings of type ClippingType_Bookmark, which
+	// only have a source and a description line. Bookmarks are skipped if it is false.
+	KeepBookmarks bool
+
+	logger *zap.Logger
 }
 
 type LineType int
@@ -32,12 +38,20 @@ const (
 
 const KindleClippingLimitMessage = "<You have reached the clipping limit for this item>"
 
+// Variant is the format of the description line of a clipping. Kindle writes the description line
+// in the language of the device, and its format has changed with software updates.
 type Variant int
 
+// The values of the variants are written to the output of cmd/parse and are listed in the schema of
+// a clipping, so they must not change: new variants are added at the end. Variant_None is not a
+// format; KindleWriter uses it to write each clipping in its own variant.
 const (
 	Variant_English_BooksWithPageNum Variant = iota
 	Variant_English_BooksWithoutPageNum
 	Variant_Japanese
+	Variant_English_Since2023_BooksWithPageNum
+	Variant_English_Since2023_BooksWithoutPageNum
+	Variant_None
 )
 
 const KindleClippingsSeparator = "=========="
@@ -45,6 +59,7 @@ const KindleClippingsSeparator = "=========="
 var KindleClippingsSeparatorMatcher *regexp.Regexp = regexp.MustCompile(`={10}`)
 
 type KindleDescriptionLineVariation struct {
+	Variant               Variant
 	Matcher               *regexp.Regexp
 	RequiredMatchCount    int
 	Type                  [2]int
@@ -60,6 +75,7 @@ var KindleDescriptionLineVariations []KindleDescriptionLineVariation = []KindleD
 	// "- Your Highlight on page 373 | location 5709-5720 | Added on Sunday, 16 April 2023 10:13:54"
 	// "- Your Note on page 286 | location 4371 | Added on Saturday, 15 April 2023 12:51:43"
 	{
+		Variant:               Variant_English_BooksWithPageNum,
 		Matcher:               regexp.MustCompile(`^- Your (.+?) on page ([ivx0-9]+) \| location (\d+)-?(\d+)? \| Added on (.+)$`),
 		RequiredMatchCount:    12,
 		Type:                  [2]int{2, 3},
@@ -73,6 +89,7 @@ var KindleDescriptionLineVariations []KindleDescriptionLineVariation = []KindleD
 	// "- Your Highlight at location 9723-9727 | Added on Sunday, 2 January 2022 13:17:22"
 	// "- Your Note at location 9727 | Added on Sunday, 2 January 2022 13:17:46"
 	{
+		Variant:               Variant_English_BooksWithoutPageNum,
 		Matcher:               regexp.MustCompile(`^- Your (.+?) at location (\d+)-?(\d+)? \| Added on (.+)$`),
 		RequiredMatchCount:    10,
 		Type:                  [2]int{2, 3},
@@ -87,6 +104,7 @@ var KindleDescriptionLineVariations []KindleDescriptionLineVariation = []KindleD
 	// "- 7ページ|位置No. 96-96のハイライト |作成日: 2023年5月14日日曜日 11:31:52"
 	// "- 1ページ|位置No. 5-5のハイライト |作成日: 2023年5月13日土曜日 19:47:14"
 	{
+		Variant:               Variant_Japanese,
 		Matcher:               regexp.MustCompile(`- (\d+)ページ\|位置No. (\d+)-?(\d+)?の(.+) \|作成日: (.+)`),
 		RequiredMatchCount:    12,
 		Type:                  [2]int{8, 9},
@@ -94,13 +112,14 @@ var KindleDescriptionLineVariations []KindleDescriptionLineVariation = []KindleD
 		LocationInSourceStart: [2]int{4, 5},
 		LocationInSourceEnd:   [2]int{6, 7},
 		CreateTime:            [2]int{10, 11},
-		CreateTimeFormat:      "2006年1月2日",
+		CreateTimeFormat:      "2006年1月2日 15:04:05",
 	},
 	// Sample line - Variation 4 - English (since June 2023)
 	// Probably because of a Kindle software update
 	//
 	// - Your Highlight on page 4 | Location 52-54 | Added on Wednesday, June 14, 2023 10:34:06 PM
 	{
+		Variant:               Variant_English_Since2023_BooksWithPageNum,
 		Matcher:               regexp.MustCompile(`^- Your (.+?) on page ([ivx0-9]+) \| Location (\d+)-?(\d+)? \| Added on (.+)$`),
 		RequiredMatchCount:    12,
 		Type:                  [2]int{2, 3},
@@ -114,6 +133,7 @@ var KindleDescriptionLineVariations []KindleDescriptionLineVariation = []KindleD
 	// Probably because of a Kindle software update
 	// - Your Highlight on Location 136-138 | Added on Tuesday, March 19, 2024 9:45:15 PM
 	{
+		Variant:               Variant_English_Since2023_BooksWithoutPageNum,
 		Matcher:               regexp.MustCompile(`^- Your (.+?) on Location (\d+)-?(\d+)? \| Added on (.+)$`),
 		RequiredMatchCount:    10,
 		Type:                  [2]int{2, 3},
@@ -145,37 +165,30 @@ var KindleDescriptionLineVariations []KindleDescriptionLineVariation = []KindleD
 //
 // --- Sample END ---
 func (k *KindleClippings) Parse() (Clippings, error) {
-	clippings, err := os.Open(k.FilePath)
-	if err != nil {
-		return nil, fmt.Errorf("could not open the clippings file > %w", err)
-	}
-
-	defer clippings.Close()
-
 	var output []Clipping
 
-	scanner := bufio.NewScanner(clippings)
-	scanner.Split(k.scanUsingKindleClippingsSeparator)
-
-	// Initial character of Kindle's file is also a separator. So, we have to scan at least once to
-	// get rid of that section.
-	scanner.Scan()
-
-	for scanner.Scan() {
-		lineContent := scanner.Bytes()
-		lineContent = bytes.TrimSpace(lineContent)
+	err := k.scanSections(func(lineContent, _ []byte) error {
 		components := bytes.SplitN(lineContent, []byte{'\n'}, 4)
 
 		if k.isException(components) {
-			continue
+			return nil
 		}
 
-		if len(lineContent) == 0 {
-			continue
+		if k.KeepBookmarks && isBookmark(components) {
+			bookmark := Clipping{}
+			if err := k.line(LineType_Source, bytes.TrimSpace(components[0]), &bookmark); err != nil {
+				return fmt.Errorf("error while parsing a line > %w", err)
+			}
+			if err := k.line(LineType_Description, bytes.TrimSpace(components[1]), &bookmark); err != nil {
+				return fmt.Errorf("error while parsing a line > %w", err)
+			}
+
+			output = append(output, bookmark)
+			return nil
 		}
 
 		if len(components) != 4 {
-			return nil, fmt.Errorf("incorrect clipping section found of length %d: %s", len(lineContent), string(lineContent))
+			return fmt.Errorf("incorrect clipping section found of length %d: %s", len(lineContent), string(lineContent))
 		}
 
 		currentClipping := Clipping{}
@@ -199,18 +212,125 @@ func (k *KindleClippings) Parse() (Clippings, error) {
 		}
 
 		for _, line := range lines {
-			err := k.line(line.lineType, bytes.TrimSpace(line.text), &currentClipping)
+			// The text of the clipping is trimmed in line, which keeps the untrimmed text for the
+			// writer.
+			text := line.text
+			if line.lineType != LineType_Clipping {
+				text = bytes.TrimSpace(text)
+			}
+
+			err := k.line(line.lineType, text, &currentClipping)
 			if err != nil {
-				return nil, fmt.Errorf("error while parsing a line > %w", err)
+				return fmt.Errorf("error while parsing a line > %w", err)
 			}
 		}
 
 		output = append(output, currentClipping)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return output, nil
 }
 
+// KindleSection is a section of Kindle's clippings file, between two separator lines.
+type KindleSection struct {
+	// Raw is the section as it is in the file, from the start of its source line up to the
+	// separator, including the line break before the separator and any whitespace in between.
+	Raw []byte
+
+	// Clipping is the clipping in the section. Its type is ClippingType_Bookmark for bookmarks, which
+	// only have a source and a description line.
+	Clipping Clipping
+}
+
+// Sections returns every section of the clippings file in the order of the file, including the
+// bookmarks and the clippings which Parse skips.
+func (k *KindleClippings) Sections() ([]KindleSection, error) {
+	var output []KindleSection
+
+	err := k.scanSections(func(lineContent, raw []byte) error {
+		components := bytes.SplitN(lineContent, []byte{'\n'}, 4)
+		if len(components) < 2 {
+			return fmt.Errorf("incorrect clipping section found of length %d: %s", len(lineContent), string(lineContent))
+		}
+
+		// The scanner reuses its buffer, so the section is copied.
+		section := KindleSection{
+			Raw: append([]byte(nil), raw...),
+		}
+
+		if err := k.line(LineType_Source, bytes.TrimSpace(components[0]), &section.Clipping); err != nil {
+			return fmt.Errorf("error while parsing a line > %w", err)
+		}
+		if err := k.line(LineType_Description, bytes.TrimSpace(components[1]), &section.Clipping); err != nil {
+			return fmt.Errorf("error while parsing a line > %w", err)
+		}
+		if len(components) == 4 {
+			if err := k.line(LineType_Clipping, components[3], &section.Clipping); err != nil {
+				return fmt.Errorf("error while parsing a line > %w", err)
+			}
+		}
+
+		output = append(output, section)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// scanSections calls handle with each section of the clippings file which is not empty, without the
+// line breaks around it. Other whitespace is kept, because it can be part of the text of a clipping.
+// handle also gets the raw section, which only lacks the line break that ends the separator line
+// before it.
+func (k *KindleClippings) scanSections(handle func(section, raw []byte) error) error {
+	clippings, err := os.Open(k.FilePath)
+	if err != nil {
+		return fmt.Errorf("could not open the clippings file > %w", err)
+	}
+
+	defer clippings.Close()
+
+	scanner := bufio.NewScanner(clippings)
+	scanner.Split(k.scanUsingKindleClippingsSeparator)
+
+	first := true
+	for scanner.Scan() {
+		lineContent := scanner.Bytes()
+
+		// Kindle's file starts with a byte order mark, and some files also start with a separator.
+		// Both give an empty section before the first clipping, which is skipped below.
+		raw := lineContent
+		if first {
+			raw = bytes.TrimPrefix(raw, []byte("\uFEFF"))
+			first = false
+		} else if rest, ok := bytes.CutPrefix(raw, []byte("\r\n")); ok {
+			raw = rest
+		} else {
+			raw = bytes.TrimPrefix(raw, []byte("\n"))
+		}
+
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+
+		if err := handle(bytes.Trim(raw, "\r\n"), raw); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("could not read the clippings file > %w", err)
+	}
+
+	return nil
+}
+
 // line processes the given line or set of lines. When the line type is source, description, or
 // separator, this will definitely be a single line. But if the lineType is clipping, then it can be
 // multiline because we are using SplitN with N = 4.
@@ -226,6 +346,7 @@ func (k *KindleClippings) line(lineType LineType, lineText []byte, clipping *Cli
 		// character as a separator, or to indicate the nature of the text that is inside each
 		// clipping section.
 		clipping.Source = strings.TrimFunc(string(lineText), notPrint)
+		clipping.setSource(k.Aliases.Canonical(clipping.Source))
 	case LineType_Description:
 		var variationErrors []error
 		for variantNum, variation := range KindleDescriptionLineVariations {
@@ -242,8 +363,13 @@ func (k *KindleClippings) line(lineType LineType, lineText []byte, clipping *Cli
 				clipping.Type = ClippingType_Highlight
 			case "Note", "メモ":
 				clipping.Type = ClippingType_Note
+			case "Bookmark", "ブックマーク":
+				clipping.Type = ClippingType_Bookmark
 			}
 
+			variant := variation.Variant
+			clipping.Variant = &variant
+
 			var err error
 
 			if variation.Page[0] != -1 {
@@ -267,19 +393,20 @@ func (k *KindleClippings) line(lineType LineType, lineText []byte, clipping *Cli
 				creationTime := lineText[matches[variation.CreateTime[0]]:matches[variation.CreateTime[1]]]
 				timeToParse := creationTime
 
-				if variantNum == int(Variant_Japanese) {
-					// dateDay = 2023年5月15日月曜日
-					dateDay, _, _ := bytes.Cut(creationTime, []byte(" "))
+				if variation.Variant == Variant_Japanese {
+					// dateDay = 2023年5月15日月曜日, timeOfDay = 20:45:04
+					dateDay, timeOfDay, _ := bytes.Cut(creationTime, []byte(" "))
 
-					// We need to remove the last three runes. Not the last three bytes.
+					// We need to remove the weekday, which is the last three runes. Not the last three
+					// bytes.
 					// dateOnly = 2023年5月15日
 					dateDayAsRunes := bytes.Runes(dateDay)
-					var dateOnly string
-					for _, v := range dateDayAsRunes[:len(dateDayAsRunes)-3] {
-						dateOnly += string(v)
+					if len(dateDayAsRunes) < 3 {
+						return fmt.Errorf(`description line > creation time does not have a weekday: "%s"`, lineText)
 					}
+					dateOnly := string(dateDayAsRunes[:len(dateDayAsRunes)-3])
 
-					timeToParse = []byte(dateOnly)
+					timeToParse = []byte(dateOnly + " " + string(timeOfDay))
 				}
 
 				clipping.CreateTime, err = time.ParseInLocation(variation.CreateTimeFormat, string(timeToParse), time.Local)
@@ -296,13 +423,17 @@ func (k *KindleClippings) line(lineType LineType, lineText []byte, clipping *Cli
 		}
 
 	case LineType_Clipping:
-		clipping.Text = string(bytes.TrimSpace(
+		rawText := bytes.TrimRight(
 			bytes.TrimSuffix(
 				lineText,
 				[]byte(KindleClippingsSeparator),
 			),
-		),
+			"\r\n",
 		)
+		clipping.Text = string(bytes.TrimSpace(rawText))
+		if len(rawText) != len(clipping.Text) {
+			clipping.RawText = string(rawText)
+		}
 	}
 
 	return nil
@@ -347,10 +478,8 @@ func (k *KindleClippings) scanUsingKindleClippingsSeparator(data []byte, atEOF b
 func (k *KindleClippings) isException(comps [][]byte) bool {
 	// Bookmark type clippings are included in Kindle's My Clippings text file and have only 2
 	// lines. The first line contains the source, whereas the second line contains the Bookmark,
-	// which has location information. We should ignore these.
-	if len(comps) == 2 &&
-		(bytes.HasPrefix(comps[1], []byte("- Your Bookmark")) ||
-			strings.Contains(string(comps[1]), `ブックマーク`)) {
+	// which has location information. We should ignore these, unless they are kept.
+	if !k.KeepBookmarks && isBookmark(comps) {
 		return true
 	}
 
@@ -366,3 +495,10 @@ func (k *KindleClippings) isException(comps [][]byte) bool {
 
 	return false
 }
+
+// isBookmark returns true if the lines of a section are a bookmark.
+func isBookmark(comps [][]byte) bool {
+	return len(comps) == 2 &&
+		(bytes.HasPrefix(comps[1], []byte("- Your Bookmark")) ||
+			strings.Contains(string(comps[1]), `ブックマーク`))
+}
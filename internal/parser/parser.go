@@ -13,10 +13,21 @@ func NewParser(inputFilePath string) Parser {
 	}
 }
 
-func NewParserWithLogger(inputFilePath string, removeClippingLimitMessages bool, logger *zap.Logger) Parser {
+func NewParserWithLogger(inputFilePath string, removeClippingLimitMessages bool, aliases SourceAliases, logger *zap.Logger) Parser {
 	return &KindleClippings{
 		FilePath:                     inputFilePath,
 		RemoveClippingLimitClippings: removeClippingLimitMessages,
+		Aliases:                      aliases,
 		logger:                       logger,
 	}
 }
+
+// NewKindleClippingsWithLogger returns the parser of Kindle's clippings file itself, which can also
+// return every section of the file.
+func NewKindleClippingsWithLogger(inputFilePath string, aliases SourceAliases, logger *zap.Logger) *KindleClippings {
+	return &KindleClippings{
+		FilePath: inputFilePath,
+		Aliases:  aliases,
+		logger:   logger,
+	}
+}
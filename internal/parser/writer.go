@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// variantNames are the names of the variants which can be chosen for KindleWriter. The variants of
+// books with and without page numbers have the same name, because the writer chooses between them
+// for each clipping.
+var variantNames = []struct {
+	name    string
+	variant Variant
+}{
+	{name: "auto", variant: Variant_None},
+	{name: "english", variant: Variant_English_BooksWithPageNum},
+	{name: "english-2023", variant: Variant_English_Since2023_BooksWithPageNum},
+	{name: "japanese", variant: Variant_Japanese},
+}
+
+// VariantNames returns the names which are accepted by ParseVariant.
+func VariantNames() []string {
+	var names []string
+	for _, v := range variantNames {
+		names = append(names, v.name)
+	}
+
+	return names
+}
+
+// ParseVariant returns the variant with the given name. An empty name is the same as "auto".
+func ParseVariant(name string) (Variant, error) {
+	if name == "" {
+		return Variant_None, nil
+	}
+
+	for _, v := range variantNames {
+		if v.name == name {
+			return v.variant, nil
+		}
+	}
+
+	return Variant_None, fmt.Errorf("variant must be one of %s, not '%s'", strings.Join(VariantNames(), ", "), name)
+}
+
+// japaneseWeekdays are the names of the days of the week in the Japanese description line, starting
+// from Sunday.
+var japaneseWeekdays = []string{"日", "月", "火", "水", "木", "金", "土"}
+
+// KindleWriter writes clippings in the format of Kindle's My Clippings.txt file, so that clippings
+// which were cleaned, deduplicated or merged can be put back on a device. Parsing a file with
+// KeepBookmarks and writing the clippings with the options that match the file gives the same bytes,
+// because the writer uses the untrimmed text of the clippings. The untrimmed text is not written to
+// the output of cmd/parse, so the whitespace around the text of a clipping is lost there.
+type KindleWriter struct {
+	// Variant is the format of the description lines. With Variant_None, each clipping is written in
+	// the variant that it was read in, and clippings from other origins are written in
+	// DefaultWriterVariant. Variant_None is not the zero value, so it must be set.
+	Variant Variant
+
+	// CRLF ends lines with "\r\n", as Kindle does.
+	CRLF bool
+
+	// ByteOrderMark starts the file with the byte order mark, as Kindle does.
+	ByteOrderMark bool
+
+	// LeadingSeparator starts the file with a separator line.
+	LeadingSeparator bool
+}
+
+// DefaultWriterVariant is the variant of clippings which were not read from Kindle's clippings file,
+// when KindleWriter does not have a variant.
+const DefaultWriterVariant = Variant_English_Since2023_BooksWithPageNum
+
+// Write writes the clippings in the given order. Kindle adds clippings at the end of the file, so
+// clippings which are sorted by their create time are in the same order as on the device.
+func (k *KindleWriter) Write(w io.Writer, clippings Clippings) error {
+	newline := "\n"
+	if k.CRLF {
+		newline = "\r\n"
+	}
+
+	writer := bufio.NewWriter(w)
+
+	if k.ByteOrderMark {
+		writer.WriteString("\uFEFF")
+	}
+
+	if k.LeadingSeparator {
+		writer.WriteString(KindleClippingsSeparator + newline)
+	}
+
+	for i, clipping := range clippings {
+		description, err := k.description(clipping)
+		if err != nil {
+			return fmt.Errorf("could not write clipping %d from source '%s' > %w", i, clipping.Source, err)
+		}
+
+		text := clipping.Text
+		if clipping.RawText != "" {
+			text = clipping.RawText
+		}
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+		if k.CRLF {
+			text = strings.ReplaceAll(text, "\n", "\r\n")
+		}
+
+		writer.WriteString(clipping.Source + newline)
+		writer.WriteString(description + newline)
+		writer.WriteString(newline)
+		writer.WriteString(text + newline)
+		writer.WriteString(KindleClippingsSeparator + newline)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("could not write clippings > %w", err)
+	}
+
+	return nil
+}
+
+// description returns the description line of the clipping, without the newline.
+func (k *KindleWriter) description(clipping Clipping) (string, error) {
+	variant := k.Variant
+	if variant == Variant_None && clipping.Variant != nil {
+		variant = *clipping.Variant
+	}
+	if variant == Variant_None {
+		variant = DefaultWriterVariant
+	}
+
+	// The variants of books with and without page numbers are chosen by the page of the clipping.
+	hasPage := clipping.Page != ""
+	switch {
+	case variant == Variant_English_BooksWithoutPageNum && hasPage:
+		variant = Variant_English_BooksWithPageNum
+	case variant == Variant_English_BooksWithPageNum && !hasPage:
+		variant = Variant_English_BooksWithoutPageNum
+	case variant == Variant_English_Since2023_BooksWithoutPageNum && hasPage:
+		variant = Variant_English_Since2023_BooksWithPageNum
+	case variant == Variant_English_Since2023_BooksWithPageNum && !hasPage:
+		variant = Variant_English_Since2023_BooksWithoutPageNum
+	case variant == Variant_Japanese && !hasPage:
+		return "", errors.New("the Japanese description line must have a page")
+	}
+
+	var clippingType string
+	switch clipping.Type {
+	case ClippingType_Highlight:
+		clippingType = "Highlight"
+		if variant == Variant_Japanese {
+			clippingType = "ハイライト"
+		}
+	case ClippingType_Note:
+		clippingType = "Note"
+		if variant == Variant_Japanese {
+			clippingType = "メモ"
+		}
+	case ClippingType_Bookmark:
+		clippingType = "Bookmark"
+		if variant == Variant_Japanese {
+			clippingType = "ブックマーク"
+		}
+	default:
+		return "", fmt.Errorf("clipping type %d can not be written", clipping.Type)
+	}
+
+	location := fmt.Sprintf("%d", clipping.LocationInSource.Start)
+	if clipping.LocationInSource.End != 0 {
+		location = fmt.Sprintf("%d-%d", clipping.LocationInSource.Start, clipping.LocationInSource.End)
+	}
+
+	// The create time is written in its own location, which is the local time of the device for
+	// clippings that were parsed from Kindle's file.
+	createTime := clipping.CreateTime
+	switch variant {
+	case Variant_English_BooksWithPageNum:
+		return fmt.Sprintf("- Your %s on page %s | location %s | Added on %s", clippingType, clipping.Page, location, createTime.Format("Monday, 2 January 2006 15:04:05")), nil
+	case Variant_English_BooksWithoutPageNum:
+		return fmt.Sprintf("- Your %s at location %s | Added on %s", clippingType, location, createTime.Format("Monday, 2 January 2006 15:04:05")), nil
+	case Variant_Japanese:
+		// The hour is not padded with a zero, which the layouts of the time package can not express.
+		added := fmt.Sprintf("%s%s曜日 %d:%02d:%02d", createTime.Format("2006年1月2日"), japaneseWeekdays[createTime.Weekday()], createTime.Hour(), createTime.Minute(), createTime.Second())
+		return fmt.Sprintf("- %sページ|位置No. %sの%s |作成日: %s", clipping.Page, location, clippingType, added), nil
+	case Variant_English_Since2023_BooksWithPageNum:
+		return fmt.Sprintf("- Your %s on page %s | Location %s | Added on %s", clippingType, clipping.Page, location, createTime.Format("Monday, January 2, 2006 3:04:05 PM")), nil
+	case Variant_English_Since2023_BooksWithoutPageNum:
+		return fmt.Sprintf("- Your %s on Location %s | Added on %s", clippingType, location, createTime.Format("Monday, January 2, 2006 3:04:05 PM")), nil
+	default:
+		return "", fmt.Errorf("variant %d can not be written", variant)
+	}
+}
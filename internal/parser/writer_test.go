@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestKindleWriterRoundTrip(t *testing.T) {
+	tests := []struct {
+		file   string
+		writer KindleWriter
+	}{
+		{
+			file:   "english.txt",
+			writer: KindleWriter{Variant: Variant_None, CRLF: true, ByteOrderMark: true},
+		},
+		{
+			file:   "english-2023.txt",
+			writer: KindleWriter{Variant: Variant_None, CRLF: true, ByteOrderMark: true},
+		},
+		{
+			file:   "japanese.txt",
+			writer: KindleWriter{Variant: Variant_None, CRLF: true, ByteOrderMark: true},
+		},
+		{
+			file:   "hand-edited.txt",
+			writer: KindleWriter{Variant: Variant_None, LeadingSeparator: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			path := filepath.Join("testdata", test.file)
+			original, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("could not read the fixture: %v", err)
+			}
+
+			k := NewKindleClippingsWithLogger(path, nil, zap.NewNop())
+			k.KeepBookmarks = true
+			clippings, err := k.Parse()
+			if err != nil {
+				t.Fatalf("could not parse the fixture: %v", err)
+			}
+
+			var written bytes.Buffer
+			if err := test.writer.Write(&written, clippings); err != nil {
+				t.Fatalf("could not write the clippings: %v", err)
+			}
+
+			if !bytes.Equal(written.Bytes(), original) {
+				t.Errorf("written file is not the same as the fixture\nwant:\n%q\ngot:\n%q", original, written.Bytes())
+			}
+		})
+	}
+}
+
+func TestParseVariants(t *testing.T) {
+	k := NewKindleClippingsWithLogger(filepath.Join("testdata", "english.txt"), nil, zap.NewNop())
+	clippings, err := k.Parse()
+	if err != nil {
+		t.Fatalf("could not parse the fixture: %v", err)
+	}
+
+	for _, clipping := range clippings {
+		if clipping.Type == ClippingType_Bookmark {
+			t.Errorf("bookmark at location %d is returned without KeepBookmarks", clipping.LocationInSource.Start)
+		}
+	}
+
+	if got, want := clippings[0].Text, "They were bell-shaped and ruffled, gracefully waving and lovely under the sea."; got != want {
+		t.Errorf("text of the first clipping is %q, want %q", got, want)
+	}
+	if got, want := clippings[0].RawText, "They were bell-shaped and ruffled, gracefully waving and lovely under the sea. "; got != want {
+		t.Errorf("raw text of the first clipping is %q, want %q", got, want)
+	}
+}
+
+// TestVariantValues pins the values of the variants, which are written to the output of cmd/parse.
+func TestVariantValues(t *testing.T) {
+	tests := []struct {
+		variant Variant
+		value   int
+	}{
+		{variant: Variant_English_BooksWithPageNum, value: 0},
+		{variant: Variant_English_BooksWithoutPageNum, value: 1},
+		{variant: Variant_Japanese, value: 2},
+		{variant: Variant_English_Since2023_BooksWithPageNum, value: 3},
+		{variant: Variant_English_Since2023_BooksWithoutPageNum, value: 4},
+		{variant: Variant_None, value: 5},
+	}
+
+	for _, test := range tests {
+		if int(test.variant) != test.value {
+			t.Errorf("variant %d must have the value %d", test.variant, test.value)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package readinglist
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+)
+
+// WriteCSV writes the entries as CSV with a header row. The sources in which each entry was found
+// are joined with "; ".
+func WriteCSV(w io.Writer, entries []Entry) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"Title", "Authors", "ISBN", "URL", "Note", "Highlight", "Sources"}); err != nil {
+		return fmt.Errorf("could not write CSV header > %w", err)
+	}
+
+	for _, entry := range entries {
+		if err := writer.Write([]string{entry.Title, entry.Authors, entry.ISBN, entry.URL, entry.Note, entry.Highlight, strings.Join(entry.Sources(), "; ")}); err != nil {
+			return fmt.Errorf("could not write CSV row > %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("could not flush CSV > %w", err)
+	}
+
+	return nil
+}
+
+// bibTeXURLReplacer escapes the characters of a URL which LaTeX would read as commands. Other
+// characters are kept, so that the URL still works when it is copied from the file.
+var bibTeXURLReplacer = strings.NewReplacer(
+	`%`, `\%`,
+	`#`, `\#`,
+	`&`, `\&`,
+	`_`, `\_`,
+)
+
+// WriteBibTeX writes the entries as BibTeX. Entries with an ISBN, or with a title and no URL, are
+// books; all other entries are miscellaneous references. Entries which have neither a title nor a
+// URL can not be cited, so they are skipped.
+func WriteBibTeX(w io.Writer, entries []Entry) error {
+	citationKeys := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Title == "" && entry.URL == "" {
+			continue
+		}
+
+		citationKey := entry.citationKey()
+		for suffix := 'a'; citationKeys[citationKey]; suffix++ {
+			citationKey = fmt.Sprintf("%s%c", entry.citationKey(), suffix)
+		}
+		citationKeys[citationKey] = true
+
+		entryType := "misc"
+		if entry.ISBN != "" || entry.URL == "" {
+			entryType = "book"
+		}
+
+		var builder strings.Builder
+		fmt.Fprintf(&builder, "@%s{%s,\n", entryType, citationKey)
+		if entry.Title != "" {
+			fmt.Fprintf(&builder, "  title = {%s},\n", utils.EscapeLaTeX(entry.Title))
+		}
+		if entry.Authors != "" {
+			fmt.Fprintf(&builder, "  author = {%s},\n", utils.EscapeLaTeX(bibTeXAuthors(entry.Authors)))
+		}
+		if entry.ISBN != "" {
+			fmt.Fprintf(&builder, "  isbn = {%s},\n", entry.ISBN)
+		}
+		if entry.URL != "" {
+			fmt.Fprintf(&builder, "  url = {%s},\n", bibTeXURLReplacer.Replace(entry.URL))
+		}
+		fmt.Fprintf(&builder, "  note = {Referenced in %s},\n", utils.EscapeLaTeX(strings.Join(entry.Sources(), "; ")))
+		builder.WriteString("}\n\n")
+
+		if _, err := io.WriteString(w, builder.String()); err != nil {
+			return fmt.Errorf("could not write BibTeX entry > %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Sources returns the names of the sources in which the entry was found, without duplicates.
+func (e Entry) Sources() []string {
+	var sources []string
+	seen := make(map[string]bool)
+	for _, reference := range e.References {
+		if !seen[reference.Source] {
+			seen[reference.Source] = true
+			sources = append(sources, reference.Source)
+		}
+	}
+
+	return sources
+}
+
+// citationKey returns the last name of the first author followed by the first word of the title,
+// e.g. "dawkins-selfish" for "The Selfish Gene" by Richard Dawkins.
+func (e Entry) citationKey() string {
+	var parts []string
+	firstAuthor, _, _ := strings.Cut(bibTeXAuthors(e.Authors), " and ")
+	if words := strings.Fields(utils.NormalizeText(firstAuthor)); len(words) > 0 {
+		parts = append(parts, words[len(words)-1])
+	}
+
+	for _, word := range strings.Fields(utils.NormalizeText(e.Title)) {
+		switch word {
+		case "a", "an", "the":
+			continue
+		}
+		parts = append(parts, word)
+		break
+	}
+
+	// BibTeX does not allow non-ASCII characters in citation keys.
+	for i, part := range parts {
+		parts[i] = strings.Map(func(r rune) rune {
+			if r < unicode.MaxASCII {
+				return r
+			}
+			return -1
+		}, part)
+	}
+
+	key := strings.Trim(strings.Join(parts, "-"), "-")
+	if key == "" {
+		return "reference"
+	}
+
+	return key
+}
+
+// bibTeXAuthors joins the authors with "and", which is the separator that BibTeX understands.
+func bibTeXAuthors(authors string) string {
+	authors = strings.ReplaceAll(authors, " & ", " and ")
+	authors = strings.ReplaceAll(authors, ", ", " and ")
+	return authors
+}
@@ -0,0 +1,291 @@
+package readinglist
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+)
+
+// Reference is the place in a book where an entry of the reading list was found.
+type Reference struct {
+	Source   string
+	Location int
+	Page     string
+}
+
+// Entry is a single book, article or web page which I want to add to my reading list. Title,
+// Authors, ISBN and URL are empty when they could not be found in the note or the highlight.
+type Entry struct {
+	Title   string
+	Authors string
+	ISBN    string
+	URL     string
+
+	// Note is the text of the reading list note after the tag. Highlight is the text of the highlight
+	// that the note is attached to.
+	Note      string
+	Highlight string
+
+	// References are all the places at which this entry was found, in the order in which they were
+	// found.
+	References []Reference
+}
+
+var (
+	// Titles are usually quoted in the text of a book. Japanese books use 『』 for the titles of
+	// books and 「」 for the titles of articles.
+	titleRx = regexp.MustCompile(`"([^"]+)"|“([^”]+)”|『([^』]+)』|「([^」]+)」`)
+
+	// Authors are the capitalized words after "by", with an optional second author after "and".
+	authorsRx = regexp.MustCompile(`\bby ((?:[A-Z][\p{L}.'-]*\s?)+(?:(?:,| and| &) (?:[A-Z][\p{L}.'-]*\s?)+)*)`)
+
+	// A candidate for an ISBN-10 or an ISBN-13, with or without hyphens and spaces between the groups
+	// of digits. Years and other numbers also match, so candidates are checked with isbns.
+	isbnRx = regexp.MustCompile(`(?i)\b(?:ISBN(?:-1[03])?:?\s*)?((?:97[89][- ]?)?\d{1,5}[- ]?\d{1,7}[- ]?\d{1,7}[- ]?[\dX])\b`)
+
+	urlRx = regexp.MustCompile(`https?://[^\s<>"]+`)
+)
+
+// Extract returns the reading list from all the notes in the input which start with a tag of the
+// reading list role, e.g. #read. Entries which refer to the same book are merged into a single
+// entry, and the entries are sorted by title.
+func Extract(input parser.Clippings, vocabulary markup.Markup) []Entry {
+	clippings := make(parser.Clippings, len(input))
+	copy(clippings, input)
+	sort.Sort(clippings)
+
+	var entries []Entry
+	index := make(map[string]int)
+	for i, clipping := range clippings {
+		if clipping.Type != parser.ClippingType_Note {
+			continue
+		}
+
+		note, ok := vocabulary.Is(clipping.Text, markup.Role_ReadingList)
+		if !ok {
+			continue
+		}
+
+		entry := Entry{
+			Note: note,
+			References: []Reference{{
+				Source:   clipping.Source,
+				Location: clipping.LocationInSource.Start,
+				Page:     clipping.Page,
+			}},
+		}
+
+		if j, ok := clippings.HighlightForNote(i); ok {
+			entry.Highlight = clippings[j].Text
+		}
+
+		// The note is what I typed myself, so it is more reliable than the highlight.
+		for _, text := range []string{entry.Note, entry.Highlight} {
+			fill(&entry, text)
+		}
+
+		// A note without quotes is usually just the name of the book, optionally followed by its
+		// authors, e.g. "#read The Selfish Gene by Richard Dawkins".
+		if entry.Title == "" && entry.Note != "" {
+			title := urlRx.ReplaceAllString(entry.Note, "")
+			title = removeISBNs(title)
+			if i := strings.Index(title, " by "); i != -1 {
+				title = title[:i]
+			}
+			entry.Title = strings.Trim(strings.TrimSpace(title), ",.;:")
+		}
+
+		existing, found := -1, false
+		for _, key := range entry.keys() {
+			if existing, found = index[key]; found {
+				break
+			}
+		}
+
+		if found {
+			merge(&entries[existing], entry)
+		} else {
+			existing = len(entries)
+			entries = append(entries, entry)
+		}
+
+		// The merged entry can have more keys than either of the entries alone, e.g. the title from
+		// one note and the ISBN from another note.
+		for _, key := range entries[existing].keys() {
+			if _, ok := index[key]; !ok {
+				index[key] = existing
+			}
+		}
+	}
+
+	// Entries without a title are at the end of the list, in the order in which they were found.
+	sort.SliceStable(entries, func(i, j int) bool {
+		if (entries[i].Title == "") != (entries[j].Title == "") {
+			return entries[j].Title == ""
+		}
+		return utils.NormalizeText(entries[i].Title) < utils.NormalizeText(entries[j].Title)
+	})
+
+	return entries
+}
+
+// fill sets the fields of the entry which are still empty from the text.
+func fill(entry *Entry, text string) {
+	if entry.Title == "" {
+		if match := titleRx.FindStringSubmatch(text); match != nil {
+			for _, group := range match[1:] {
+				if group != "" {
+					entry.Title = strings.TrimSpace(group)
+					break
+				}
+			}
+		}
+	}
+
+	// The ISBN and the URL are removed, so that they are not mistaken for the name of an author.
+	if entry.Authors == "" {
+		if match := authorsRx.FindStringSubmatch(removeISBNs(urlRx.ReplaceAllString(text, ""))); match != nil {
+			entry.Authors = strings.TrimSpace(match[1])
+		}
+	}
+
+	if entry.ISBN == "" {
+		if found := isbns(text); len(found) > 0 {
+			entry.ISBN = found[0].isbn
+		}
+	}
+
+	if entry.URL == "" {
+		if url := urlRx.FindString(text); url != "" {
+			entry.URL = strings.TrimRight(url, ".,;:)")
+		}
+	}
+}
+
+// isbnMatch is an ISBN in a text, with the position of the text which was matched.
+type isbnMatch struct {
+	isbn       string
+	start, end int
+}
+
+// isbns returns the ISBNs in the text. A candidate is an ISBN if it has the length of an ISBN and
+// either follows "ISBN" or has a valid check digit, so that numbers in titles, e.g. "1984", are not
+// mistaken for ISBNs.
+func isbns(text string) []isbnMatch {
+	var output []isbnMatch
+	for _, match := range isbnRx.FindAllStringSubmatchIndex(text, -1) {
+		isbn := normalizeISBN(text[match[2]:match[3]])
+		if isbn == "" {
+			continue
+		}
+
+		hasPrefix := match[2] > match[0]
+		if !hasPrefix && !validISBN(isbn) {
+			continue
+		}
+
+		output = append(output, isbnMatch{isbn: isbn, start: match[0], end: match[1]})
+	}
+
+	return output
+}
+
+// removeISBNs returns the text without the ISBNs in it.
+func removeISBNs(text string) string {
+	var builder strings.Builder
+	previous := 0
+	for _, match := range isbns(text) {
+		builder.WriteString(text[previous:match.start])
+		previous = match.end
+	}
+	builder.WriteString(text[previous:])
+
+	return builder.String()
+}
+
+// validISBN returns true if the check digit of the normalized ISBN-10 or ISBN-13 is valid.
+func validISBN(isbn string) bool {
+	sum := 0
+	for i, r := range isbn {
+		digit := int(r - '0')
+		if r == 'X' {
+			// X is 10, and only the check digit of an ISBN-10 can be X.
+			if len(isbn) != 10 || i != 9 {
+				return false
+			}
+			digit = 10
+		}
+
+		if len(isbn) == 10 {
+			sum += (10 - i) * digit
+		} else if i%2 == 0 {
+			sum += digit
+		} else {
+			sum += 3 * digit
+		}
+	}
+
+	if len(isbn) == 10 {
+		return sum%11 == 0
+	}
+
+	return sum%10 == 0
+}
+
+// normalizeISBN returns the ISBN without hyphens and spaces, or an empty string if it does not have
+// the length of an ISBN-10 or an ISBN-13.
+func normalizeISBN(isbn string) string {
+	isbn = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+	switch {
+	case len(isbn) == 10:
+		return isbn
+	case len(isbn) == 13 && !strings.Contains(isbn, "X"):
+		return isbn
+	default:
+		return ""
+	}
+}
+
+// keys returns the keys which identify the book that the entry refers to. Two entries which have
+// any key in common refer to the same book. When the entry has neither an ISBN, nor a URL, nor a
+// title, the text of the note and the highlight is the only key.
+func (e Entry) keys() []string {
+	var keys []string
+	if e.ISBN != "" {
+		keys = append(keys, "isbn:"+e.ISBN)
+	}
+	if e.URL != "" {
+		keys = append(keys, "url:"+e.URL)
+	}
+	if e.Title != "" {
+		keys = append(keys, "title:"+utils.NormalizeText(e.Title))
+	}
+	if len(keys) == 0 {
+		keys = append(keys, "text:"+utils.NormalizeText(e.Note+" "+e.Highlight))
+	}
+
+	return keys
+}
+
+// merge adds the references of the other entry to the entry, and fills the fields of the entry
+// which are empty.
+func merge(entry *Entry, other Entry) {
+	for _, field := range []struct{ to, from *string }{
+		{&entry.Title, &other.Title},
+		{&entry.Authors, &other.Authors},
+		{&entry.ISBN, &other.ISBN},
+		{&entry.URL, &other.URL},
+		{&entry.Note, &other.Note},
+		{&entry.Highlight, &other.Highlight},
+	} {
+		if *field.to == "" {
+			*field.to = *field.from
+		}
+	}
+
+	entry.References = append(entry.References, other.References...)
+}
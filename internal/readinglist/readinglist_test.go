@@ -0,0 +1,86 @@
+package readinglist
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		note    string
+		title   string
+		authors string
+		isbn    string
+	}{
+		{
+			note:    "#read 1984 by George Orwell",
+			title:   "1984",
+			authors: "George Orwell",
+		},
+		{
+			note:  "#read 2001: A Space Odyssey",
+			title: "2001: A Space Odyssey",
+		},
+		{
+			note:    "#read The Selfish Gene by Richard Dawkins ISBN 978-0-19-878860-7",
+			title:   "The Selfish Gene",
+			authors: "Richard Dawkins",
+			isbn:    "9780198788607",
+		},
+		{
+			note:  "#read Gödel, Escher, Bach 0465026567",
+			title: "Gödel, Escher, Bach",
+			isbn:  "0465026567",
+		},
+		{
+			// The number does not have a valid check digit, so it is not an ISBN.
+			note:  "#read Catch 1234567890",
+			title: "Catch 1234567890",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.note, func(t *testing.T) {
+			entries := Extract(parser.Clippings{{
+				Source:           "Book (Author)",
+				Type:             parser.ClippingType_Note,
+				LocationInSource: parser.Location{Start: 10},
+				Text:             test.note,
+			}}, markup.Default())
+			if len(entries) != 1 {
+				t.Fatalf("got %d entries, want 1", len(entries))
+			}
+
+			entry := entries[0]
+			if entry.Title != test.title {
+				t.Errorf("title is %q, want %q", entry.Title, test.title)
+			}
+			if entry.Authors != test.authors {
+				t.Errorf("authors are %q, want %q", entry.Authors, test.authors)
+			}
+			if entry.ISBN != test.isbn {
+				t.Errorf("ISBN is %q, want %q", entry.ISBN, test.isbn)
+			}
+		})
+	}
+}
+
+func TestWriteBibTeXEscapesURL(t *testing.T) {
+	var output bytes.Buffer
+	err := WriteBibTeX(&output, []Entry{{
+		URL:        "https://example.com/a_b?x=1&y=%20#top",
+		References: []Reference{{Source: "Book (Author)"}},
+	}})
+	if err != nil {
+		t.Fatalf("could not write BibTeX: %v", err)
+	}
+
+	want := `url = {https://example.com/a\_b?x=1\&y=\%20\#top}`
+	if !strings.Contains(output.String(), want) {
+		t.Errorf("output does not contain %q:\n%s", want, output.String())
+	}
+}
@@ -2,22 +2,46 @@ package summarizer
 
 import (
 	"errors"
-	"strconv"
+	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
 	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
 	"go.uber.org/zap"
 )
 
 type BookSummary struct {
-	Name     string
+	Name string
+
+	// Preamble holds the chapter summaries which appear before the first chapter name in the book.
+	// It does not have a name.
+	Preamble ChapterSummary
+
+	// Chapters is the flat list of all the chapters in the order in which they appear in the book.
+	// The chapters in this list do not have any children.
 	Chapters []ChapterSummary
+
+	// Tree is the list of top-level chapters. Nested chapters are inside the Children of their
+	// parent chapter.
+	Tree []ChapterSummary
+
+	// Warnings describe the markers which were not where they were expected to be, and how they
+	// were handled.
+	Warnings []string
 }
 
 type ChapterSummary struct {
-	Name             string
-	Level            string
+	Name string
+
+	// Depth is the level at which the chapter is nested, starting from 1 for top-level chapters.
+	Depth int
+
+	// Level is the Org mode heading prefix for the depth of the chapter, i.e. Depth asterisks.
+	Level string
+
 	SummaryClippings parser.Clippings
+	Children         []ChapterSummary
 }
 
 type Creator interface {
@@ -25,6 +49,8 @@ type Creator interface {
 }
 
 type KindleCreator struct {
+	// Markup has the tags of chapter names and chapter summaries.
+	Markup markup.Markup
 	Logger *zap.Logger
 }
 
@@ -33,32 +59,88 @@ func (k *KindleCreator) Summarize(input parser.Clippings) (BookSummary, error) {
 	if len(input) == 0 {
 		return BookSummary{}, errors.New("no clippings to summarize")
 	}
+
+	// Sort a copy of the input, so that markers are processed in the order in which they appear in
+	// the book, regardless of the order of the input.
+	clippings := make(parser.Clippings, len(input))
+	copy(clippings, input)
+	sort.Sort(clippings)
+
 	summ := BookSummary{}
-	summ.Name = input[0].Source
-	for i, clipping := range input {
+	summ.Name = clippings[0].Source
+	summ.Preamble = ChapterSummary{
+		SummaryClippings: []parser.Clipping{},
+	}
+	previousDepth := 0
+	for i, clipping := range clippings {
+		if clipping.Type != parser.ClippingType_Note {
+			continue
+		}
+
 		// Chapter name prefix
-		if strings.HasPrefix(clipping.Text, "#cn") {
-			// Get the chapter name from the previous highlight
-			chapterName := input[i-1].Text
+		if level, nameArgument, ok := k.Markup.ChapterName(clipping.Text); ok {
 			thisChapter := ChapterSummary{
-				Name:             chapterName,
-				Level:            "*",
+				Depth:            level,
 				SummaryClippings: []parser.Clipping{},
 			}
 
-			if parsedLevel, hasLevel := strings.CutPrefix(clipping.Text, "#cn "); hasLevel {
-				if level, err := strconv.Atoi(parsedLevel); err == nil {
-					thisChapter.Level = strings.Repeat("*", level)
-				}
+			// Get the chapter name from the highlight that this note is attached to
+			if j, ok := clippings.HighlightForNote(i); ok {
+				thisChapter.Name = clippings[j].Text
+			} else if nameArgument != "" {
+				thisChapter.Name = nameArgument
+			} else {
+				thisChapter.Name = fmt.Sprintf("Chapter at location %d", clipping.LocationInSource.Start)
+				summ.Warnings = append(summ.Warnings, fmt.Sprintf("chapter name at location %d is not attached to a highlight and does not have a name", clipping.LocationInSource.Start))
 			}
 
+			// A chapter can be nested at most one level deeper than the chapter before it. A jump
+			// from level 1 to level 4 means that some chapter names are missing. The first chapter
+			// can be at any level, because books often have chapter names only from some level
+			// onwards.
+			if previousDepth > 0 && thisChapter.Depth > previousDepth+1 {
+				return BookSummary{}, fmt.Errorf("chapter '%s' at location %d is at level %d, but the chapter before it is at level %d", thisChapter.Name, clipping.LocationInSource.Start, thisChapter.Depth, previousDepth)
+			}
+			previousDepth = thisChapter.Depth
+
+			thisChapter.Level = strings.Repeat("*", thisChapter.Depth)
 			summ.Chapters = append(summ.Chapters, thisChapter)
 		}
 
-		if strings.HasPrefix(clipping.Text, "#cs ") {
+		if _, ok := k.Markup.Is(clipping.Text, markup.Role_ChapterSummary); ok {
+			if len(summ.Chapters) == 0 {
+				summ.Warnings = append(summ.Warnings, fmt.Sprintf("chapter summary at location %d appears before the first chapter name; it was added to the preamble", clipping.LocationInSource.Start))
+				summ.Preamble.SummaryClippings = append(summ.Preamble.SummaryClippings, clipping)
+				continue
+			}
+
 			summ.Chapters[len(summ.Chapters)-1].SummaryClippings = append(summ.Chapters[len(summ.Chapters)-1].SummaryClippings, clipping)
 		}
 	}
 
+	for _, warning := range summ.Warnings {
+		k.Logger.Debug("summary warning", zap.String("source", summ.Name), zap.String("warning", warning))
+	}
+
+	for i := 0; i < len(summ.Chapters); {
+		var chapter ChapterSummary
+		chapter, i = subtree(summ.Chapters, i)
+		summ.Tree = append(summ.Tree, chapter)
+	}
+
 	return summ, nil
 }
+
+// subtree returns the chapter at index i of the flat list of chapters with all the chapters nested
+// inside it as its children, and the index of the first chapter after this subtree.
+func subtree(chapters []ChapterSummary, i int) (ChapterSummary, int) {
+	chapter := chapters[i]
+	j := i + 1
+	for j < len(chapters) && chapters[j].Depth > chapter.Depth {
+		var child ChapterSummary
+		child, j = subtree(chapters, j)
+		chapter.Children = append(chapter.Children, child)
+	}
+
+	return chapter, j
+}
@@ -0,0 +1,97 @@
+package summarizer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"go.uber.org/zap"
+)
+
+// tree writes the chapters with their depth, and their children in parentheses, e.g.
+// "A:1(B:2),C:1".
+func tree(chapters []ChapterSummary) string {
+	var parts []string
+	for _, chapter := range chapters {
+		part := fmt.Sprintf("%s:%d", chapter.Name, chapter.Depth)
+		if chapter.Level != strings.Repeat("*", chapter.Depth) {
+			part += fmt.Sprintf("[level %q]", chapter.Level)
+		}
+		if len(chapter.Children) > 0 {
+			part += "(" + tree(chapter.Children) + ")"
+		}
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func TestSummarizeTree(t *testing.T) {
+	tests := []struct {
+		name    string
+		notes   []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "chapters without levels",
+			notes: []string{"#cn One", "#cn Two"},
+			want:  "One:1,Two:1",
+		},
+		{
+			name:  "nested chapters",
+			notes: []string{"#cn 1 Part One", "#cn 2 Chapter 1", "#cn 2 Chapter 2", "#cn 1 Part Two", "#cn 2 Chapter 3"},
+			want:  "Part One:1(Chapter 1:2,Chapter 2:2),Part Two:1(Chapter 3:2)",
+		},
+		{
+			name:  "return from a deep chapter to the top level",
+			notes: []string{"#cn 1 A", "#cn 2 B", "#cn 3 C", "#cn 1 D"},
+			want:  "A:1(B:2(C:3)),D:1",
+		},
+		{
+			name:  "first chapter below the top level",
+			notes: []string{"#cn 2 Chapter 1", "#cn 1 Part Two", "#cn 2 Chapter 2"},
+			want:  "Chapter 1:2,Part Two:1(Chapter 2:2)",
+		},
+		{
+			name:    "chapter more than one level deeper than the chapter before it",
+			notes:   []string{"#cn 1 A", "#cn 3 B"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var clippings parser.Clippings
+			for i, note := range test.notes {
+				clippings = append(clippings, parser.Clipping{
+					Source:           "Book (Author)",
+					Type:             parser.ClippingType_Note,
+					LocationInSource: parser.Location{Start: 10 * (i + 1)},
+					Text:             note,
+				})
+			}
+
+			creator := KindleCreator{Markup: markup.Default(), Logger: zap.NewNop()}
+			summary, err := creator.Summarize(clippings)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("summarized without an error, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("could not summarize: %v", err)
+			}
+
+			if got := tree(summary.Tree); got != test.want {
+				t.Errorf("tree is %s, want %s", got, test.want)
+			}
+			if len(summary.Chapters) != len(test.notes) {
+				t.Errorf("got %d chapters in the flat list, want %d", len(summary.Chapters), len(test.notes))
+			}
+		})
+	}
+}
@@ -1,54 +1,677 @@
 package supplementer
 
 import (
+	"fmt"
 	"regexp"
 	"sort"
 	"strings"
 
 	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
 	"go.uber.org/zap"
 )
 
 type Bookcision struct {
+	// SourceRegex selects the Kindle sources which are the same book as the Bookcision export. All
+	// sources are selected if this is nil.
 	SourceRegex *regexp.Regexp
-	Logger      *zap.Logger
+
+	// LocationTolerance is the largest difference between the start location of a Kindle highlight
+	// and a Bookcision highlight for them to be considered the same highlight. The Kindle's file and
+	// Amazon's notebook frequently disagree by a location or two.
+	LocationTolerance int
+
+	// AddMissing adds the highlights and notes which are present in Bookcision but not in Kindle's
+	// clippings file to the output. This happens for highlights made on the phone app or on another
+	// device.
+	AddMissing bool
+
+	// PruneDeleted removes the Kindle highlights which do not have a counterpart in Bookcision,
+	// along with the notes attached to them. Kindle never removes entries from its clippings file,
+	// so highlights which were deleted on the device are still present there. Bookcision reflects
+	// the current notebook.
+	PruneDeleted bool
+
+	// ConflictPolicy decides which text is kept when a Kindle highlight and the matching Bookcision
+	// highlight have different text.
+	ConflictPolicy ConflictPolicy
+
+	Logger *zap.Logger
+}
+
+// ConflictPolicy ...
+type ConflictPolicy string
+
+const (
+	ConflictPolicy_Kindle     ConflictPolicy = "kindle"
+	ConflictPolicy_Bookcision ConflictPolicy = "bookcision"
+)
+
+// AmbiguousMatch is a clipping limit placeholder from Kindle which could have been filled by more
+// than one Bookcision highlight equally well. These placeholders are left as-is.
+type AmbiguousMatch struct {
+	Kindle     parser.Clipping  `json:"kindle"`
+	Candidates parser.Clippings `json:"candidates"`
+}
+
+// Conflict is a Kindle highlight which is not a placeholder, whose text is different from the text
+// of the matching Bookcision highlight. This happens when the highlight was truncated, edited, or
+// made in a different edition of the book.
+type Conflict struct {
+	Kindle     parser.Clipping `json:"kindle"`
+	Bookcision parser.Clipping `json:"bookcision"`
+
+	// Diff turns the text of the Kindle highlight into the text of the Bookcision highlight.
+	Diff []utils.DiffOp `json:"diff"`
+
+	// Winner is the side whose text was kept in the output.
+	Winner ConflictPolicy `json:"winner"`
+}
+
+// Report describes what happened while merging Bookcision highlights into Kindle clippings.
+type Report struct {
+	// Placeholders is the number of clipping limit placeholders in the Kindle input.
+	Placeholders int `json:"placeholders"`
+
+	// Filled contains the placeholders whose text was replaced with the text from Bookcision.
+	Filled parser.Clippings `json:"filled"`
+
+	// Unfilled contains the placeholders which did not match any Bookcision highlight.
+	Unfilled parser.Clippings `json:"unfilled"`
+
+	Ambiguous []AmbiguousMatch `json:"ambiguous"`
+
+	// Unmatched contains the Bookcision highlights which did not match any Kindle highlight.
+	Unmatched parser.Clippings `json:"unmatched"`
+
+	Conflicts []Conflict `json:"conflicts"`
+
+	// Added contains the highlights and notes which were added from Bookcision. This is empty
+	// unless AddMissing is set.
+	Added parser.Clippings `json:"added"`
+
+	// Deleted contains the Kindle highlights which do not have a counterpart in Bookcision and the
+	// notes attached to them. These are removed from the output only if PruneDeleted is set.
+	Deleted parser.Clippings `json:"deleted"`
+}
+
+// bookcisionHighlight is a highlight from Bookcision along with the note that Bookcision has
+// attached to it, if any.
+type bookcisionHighlight struct {
+	clipping parser.Clipping
+	note     *parser.Clipping
+}
+
+// candidate is a Bookcision highlight which might be the same as a Kindle highlight. Lower scores are
+// better matches.
+type candidate struct {
+	index int
+	score int
 }
 
 // Merge ...
 func (b *Bookcision) Merge(kindleInput parser.Clippings, bookcision parser.Clippings) (parser.Clippings, error) {
-	output := make(parser.Clippings, 0)
+	output, _, err := b.MergeWithReport(kindleInput, bookcision)
+	return output, err
+}
+
+// MergeWithReport matches each Kindle highlight with the Bookcision highlight which is closest to
+// it, and replaces the text of Kindle's clipping limit placeholders with the text of the matching
+// Bookcision highlight. Placeholders which match more than one Bookcision highlight equally well are
+// not filled; they are returned in the report.
+func (b *Bookcision) MergeWithReport(kindleInput parser.Clippings, bookcision parser.Clippings) (parser.Clippings, Report, error) {
+	var report Report
 
 	sort.Sort(kindleInput)
 	sort.Sort(bookcision)
 
-	j := 0
+	highlights, standaloneNotes := b.bookcisionHighlights(bookcision)
+
+	source, err := b.bookSource(kindleInput, bookcision)
+	if err != nil {
+		return nil, Report{}, err
+	}
+
+	// Kindle writes notes at the end location of the highlight that they are attached to.
+	kindleNotes := make(map[string][]int)
 	for _, kindle := range kindleInput {
-		if b.SourceRegex.MatchString(kindle.Source) && strings.Contains(kindle.Text, parser.KindleClippingLimitMessage) {
-			for ; j < len(bookcision); j++ {
-				// Kindle has moved ahead of bookcision, so increase bookcision and see whether we
-				// find something that matches
-				if bookcision[j].LocationInSource.Start < kindle.LocationInSource.Start {
-					continue
-				}
+		if kindle.Type == parser.ClippingType_Note {
+			kindleNotes[kindle.Source] = append(kindleNotes[kindle.Source], kindle.LocationInSource.Start)
+		}
+	}
 
-				// Bookcision has moved past Kindle, so there is no hope of finding anything in
-				// Bookcision either. Just break out of this loop.
-				if bookcision[j].LocationInSource.Start > kindle.LocationInSource.Start {
-					break
-				}
+	var kindleHighlights []int
+	candidates := make(map[int][]candidate)
+	for i, kindle := range kindleInput {
+		if kindle.Type != parser.ClippingType_Highlight || !b.matchesSource(kindle.Source) {
+			continue
+		}
 
-				// Bookcision has a clipping from the same location as Kindle. Replace the text from
-				// Bookcision into the Kindle list.
-				if bookcision[j].LocationInSource.Start == kindle.LocationInSource.Start {
-					b.Logger.Debug("supplemented using bookcision", zap.Any("original", kindle), zap.Any("supplemented", bookcision[j]))
-					kindle.Text = bookcision[j].Text
-					break
+		if isPlaceholder(kindle) {
+			report.Placeholders++
+		}
+
+		kindleHighlights = append(kindleHighlights, i)
+		candidates[i] = b.candidates(kindle, source, hasNote(kindle, kindleNotes[kindle.Source]), highlights)
+	}
+
+	// Highlights with the best matches choose first, so that a Bookcision highlight which is an
+	// exact match for one highlight is not used up by a highlight that is only close to it.
+	sort.SliceStable(kindleHighlights, func(i, j int) bool {
+		return bestScore(candidates[kindleHighlights[i]]) < bestScore(candidates[kindleHighlights[j]])
+	})
+
+	used := make(map[int]bool)
+	ambiguous := make(map[int]bool)
+	matches := make(map[int]int)
+	output := make(parser.Clippings, len(kindleInput))
+	copy(output, kindleInput)
+	for _, i := range kindleHighlights {
+		var available []candidate
+		for _, c := range candidates[i] {
+			if !used[c.index] {
+				available = append(available, c)
+			}
+		}
+
+		if len(available) == 0 {
+			b.Logger.Debug("no bookcision highlight found for kindle highlight", zap.Any("original", kindleInput[i]))
+			if isPlaceholder(kindleInput[i]) {
+				report.Unfilled = append(report.Unfilled, kindleInput[i])
+			}
+			continue
+		}
+
+		// The text of a highlight which is not a placeholder is already known, so any of the equally
+		// good candidates is a fine match for it. A placeholder would be filled with the wrong text.
+		if isPlaceholder(kindleInput[i]) && len(available) > 1 && available[0].score == available[1].score {
+			match := AmbiguousMatch{
+				Kindle: kindleInput[i],
+			}
+			for _, c := range available {
+				if c.score == available[0].score {
+					match.Candidates = append(match.Candidates, highlights[c.index].clipping)
 				}
 			}
+
+			b.Logger.Debug("ambiguous bookcision highlights for placeholder", zap.Any("original", kindleInput[i]), zap.Any("candidates", match.Candidates))
+			report.Ambiguous = append(report.Ambiguous, match)
+			ambiguous[i] = true
+			continue
+		}
+
+		used[available[0].index] = true
+		matches[i] = available[0].index
+
+		// Kindle's clippings file does not have these, so they are always taken from Bookcision.
+		if output[i].URL == "" {
+			output[i].URL = highlights[available[0].index].clipping.URL
+		}
+		if output[i].Authors == "" {
+			output[i].Authors = highlights[available[0].index].clipping.Authors
+		}
+
+		bookcisionText := highlights[available[0].index].clipping.Text
+		switch {
+		case isPlaceholder(kindleInput[i]):
+			b.Logger.Debug("supplemented using bookcision", zap.Any("original", kindleInput[i]), zap.Any("supplemented", highlights[available[0].index].clipping))
+			output[i].Text = bookcisionText
+			report.Filled = append(report.Filled, output[i])
+		case textDistance(kindleInput[i].Text, bookcisionText) != 0:
+			conflict := Conflict{
+				Kindle:     kindleInput[i],
+				Bookcision: highlights[available[0].index].clipping,
+				Diff:       utils.DiffWords(kindleInput[i].Text, bookcisionText),
+				Winner:     ConflictPolicy_Kindle,
+			}
+
+			if b.ConflictPolicy == ConflictPolicy_Bookcision {
+				output[i].Text = bookcisionText
+				conflict.Winner = ConflictPolicy_Bookcision
+			}
+
+			b.Logger.Debug("kindle and bookcision highlights have different text", zap.Any("conflict", conflict))
+			report.Conflicts = append(report.Conflicts, conflict)
+		}
+	}
+
+	matched := make(map[int]bool)
+	for _, index := range matches {
+		matched[index] = true
+	}
+	for index, highlight := range highlights {
+		if !matched[index] {
+			report.Unmatched = append(report.Unmatched, highlight.clipping)
+		}
+	}
+
+	// An empty Bookcision export does not say anything about which highlights are still present
+	// on the device.
+	if len(highlights) > 0 {
+		deleted := b.deleted(kindleInput, source, kindleHighlights, candidates, highlights, matches, ambiguous)
+		for _, i := range deleted {
+			report.Deleted = append(report.Deleted, kindleInput[i])
+		}
+
+		if b.PruneDeleted && len(deleted) > 0 {
+			// A source which was chosen only by the source filter can be another book than the
+			// export, so the export must also match the sources by title and authors before anything
+			// is removed.
+			if err := coversSources(report.Deleted, bookcision[0]); err != nil {
+				return nil, Report{}, fmt.Errorf("refusing to prune deleted highlights > %w", err)
+			}
+
+			output = prune(output, deleted)
+		}
+	}
+
+	sort.Sort(report.Filled)
+	sort.Sort(report.Unfilled)
+	sort.SliceStable(report.Conflicts, func(i, j int) bool {
+		return report.Conflicts[i].Kindle.LocationInSource.Start < report.Conflicts[j].Kindle.LocationInSource.Start
+	})
+
+	if b.AddMissing {
+		report.Added = b.missing(kindleInput, source, kindleNotes, highlights, standaloneNotes, matches)
+		output = append(output, report.Added...)
+	}
+
+	return output, report, nil
+}
+
+// missing returns the highlights and notes from Bookcision which do not have a counterpart in the
+// Kindle input. Notes are added to Kindle highlights which do not have a note yet, at the end
+// location of the highlight, which is where Kindle itself would have put the note. Highlights and
+// standalone notes are added to the given source, which is the book of the Bookcision export.
+func (b *Bookcision) missing(kindleInput parser.Clippings, source string, kindleNotes map[string][]int, highlights []bookcisionHighlight, standaloneNotes parser.Clippings, matches map[int]int) parser.Clippings {
+	// Clippings from Bookcision have the IDs that they would have in the clippings file of Kindle.
+	originalSource := ""
+	for _, kindle := range kindleInput {
+		if kindle.Source == source {
+			originalSource = kindle.OriginalSource
+			break
 		}
+	}
+
+	// The matches are visited in the order of the Kindle input, so that the output is the same on
+	// every run.
+	kindleIndices := make([]int, 0, len(matches))
+	for i := range matches {
+		kindleIndices = append(kindleIndices, i)
+	}
+	sort.Ints(kindleIndices)
+
+	var output parser.Clippings
+	matched := make(map[int]bool)
+	for _, i := range kindleIndices {
+		index := matches[i]
+		matched[index] = true
+
+		kindle := kindleInput[i]
+		note := highlights[index].note
+		if note == nil || hasNote(kindle, kindleNotes[kindle.Source]) {
+			continue
+		}
+
+		added := *note
+		added.Source = kindle.Source
+		added.OriginalSource = kindle.OriginalSource
+		added.Page = kindle.Page
+		added.URL = kindle.URL
+		added.LocationInSource = parser.Location{
+			Start: endLocation(kindle),
+		}
+		added.Origin = parser.Origin_Bookcision
 
-		output = append(output, kindle)
+		b.Logger.Debug("added note from bookcision", zap.Any("highlight", kindle), zap.Any("note", added))
+		output = append(output, added)
 	}
 
-	return output, nil
+	for index, highlight := range highlights {
+		if matched[index] {
+			continue
+		}
+
+		added := highlight.clipping
+		added.Source = source
+		added.OriginalSource = originalSource
+		added.Origin = parser.Origin_Bookcision
+
+		b.Logger.Debug("added highlight from bookcision", zap.Any("highlight", added))
+		output = append(output, added)
+
+		if highlight.note != nil {
+			note := *highlight.note
+			note.Source = source
+			note.OriginalSource = originalSource
+			note.Origin = parser.Origin_Bookcision
+			output = append(output, note)
+		}
+	}
+
+	for _, note := range standaloneNotes {
+		if b.hasSimilarNote(note, source, kindleInput) {
+			continue
+		}
+
+		added := note
+		added.Source = source
+		added.OriginalSource = originalSource
+		added.Origin = parser.Origin_Bookcision
+
+		b.Logger.Debug("added standalone note from bookcision", zap.Any("note", added))
+		output = append(output, added)
+	}
+
+	sort.Stable(output)
+
+	return output
+}
+
+// hasSimilarNote returns true if there is a Kindle note from the given source with the same text
+// within the location tolerance of the Bookcision note.
+func (b *Bookcision) hasSimilarNote(note parser.Clipping, source string, kindleInput parser.Clippings) bool {
+	for _, kindle := range kindleInput {
+		if kindle.Type != parser.ClippingType_Note || kindle.Source != source {
+			continue
+		}
+
+		distance := kindle.LocationInSource.Start - note.LocationInSource.Start
+		if distance < 0 {
+			distance = -distance
+		}
+
+		if distance <= b.LocationTolerance && textDistance(kindle.Text, note.Text) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deleted returns the indices of the Kindle highlights from the given source which do not have a
+// counterpart in Bookcision, along with the indices of the notes which are attached only to those
+// highlights. Placeholders are never deleted, because their text is not known and they can not be
+// compared with Bookcision.
+//
+// A highlight has a counterpart if it was matched with a Bookcision highlight, or if it is a
+// placeholder which matched more than one Bookcision highlight. Kindle's clippings file often has
+// more than one entry for the same highlight, and only one of them can be matched. So, a highlight
+// which has a candidate with similar text also has a counterpart, even if that candidate was matched
+// with another highlight.
+func (b *Bookcision) deleted(kindleInput parser.Clippings, source string, kindleHighlights []int, candidates map[int][]candidate, highlights []bookcisionHighlight, matches map[int]int, ambiguous map[int]bool) []int {
+	hasCounterpart := func(i int) bool {
+		if _, ok := matches[i]; ok || ambiguous[i] {
+			return true
+		}
+
+		for _, c := range candidates[i] {
+			if textDistance(kindleInput[i].Text, highlights[c.index].clipping.Text) < 2 {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	var deletedHighlights, keptHighlights parser.Clippings
+	var output []int
+	for _, i := range kindleHighlights {
+		if kindleInput[i].Source != source || isPlaceholder(kindleInput[i]) {
+			continue
+		}
+
+		if hasCounterpart(i) {
+			keptHighlights = append(keptHighlights, kindleInput[i])
+			continue
+		}
+
+		b.Logger.Debug("kindle highlight not found in bookcision", zap.Any("highlight", kindleInput[i]))
+		deletedHighlights = append(deletedHighlights, kindleInput[i])
+		output = append(output, i)
+	}
+
+	for i, kindle := range kindleInput {
+		if kindle.Type != parser.ClippingType_Note || kindle.Source != source {
+			continue
+		}
+
+		if attachedTo(kindle, deletedHighlights) && !attachedTo(kindle, keptHighlights) {
+			output = append(output, i)
+		}
+	}
+
+	sort.Ints(output)
+
+	return output
+}
+
+// coversSources returns an error unless the title and the authors of the Bookcision export match the
+// source of one of the given clippings.
+func coversSources(clippings parser.Clippings, bookcision parser.Clipping) error {
+	sourceSet := make(map[string]bool)
+	for _, clipping := range clippings {
+		sourceSet[clipping.Source] = true
+	}
+
+	sources := make([]string, 0, len(sourceSet))
+	for source := range sourceSet {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	if _, err := MatchSource(sources, bookcision.Source, bookcision.Authors); err != nil {
+		return fmt.Errorf("bookcision export does not cover any of the sources %v > %w", sources, err)
+	}
+
+	return nil
+}
+
+// prune returns the clippings which are not at the given sorted indices.
+func prune(input parser.Clippings, indices []int) parser.Clippings {
+	output := make(parser.Clippings, 0, len(input))
+	j := 0
+	for i, clipping := range input {
+		if j < len(indices) && indices[j] == i {
+			j++
+			continue
+		}
+
+		output = append(output, clipping)
+	}
+
+	return output
+}
+
+// bookSource returns the Kindle source which is the same book as the Bookcision export. If only one
+// Kindle source matches the source regular expression, then that is the book, even if its title is
+// different, e.g. after the metadata of the book was edited in Calibre. If more than one source
+// matches, then the book is chosen among them using the title and the authors from Bookcision, and an
+// error is returned unless exactly one of them is the book. If no source matches, then the title of
+// the book from Bookcision is used.
+func (b *Bookcision) bookSource(kindleInput parser.Clippings, bookcision parser.Clippings) (string, error) {
+	if len(bookcision) == 0 {
+		return "", nil
+	}
+
+	sourceSet := make(map[string]bool)
+	for _, kindle := range kindleInput {
+		if b.matchesSource(kindle.Source) {
+			sourceSet[kindle.Source] = true
+		}
+	}
+
+	sources := make([]string, 0, len(sourceSet))
+	for source := range sourceSet {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	switch len(sources) {
+	case 0:
+		return bookcision[0].Source, nil
+	case 1:
+		return sources[0], nil
+	}
+
+	source, err := MatchSource(sources, bookcision[0].Source, bookcision[0].Authors)
+	if err != nil {
+		return "", fmt.Errorf("could not choose the source of the bookcision export among the %d sources which match the source filter > %w", len(sources), err)
+	}
+
+	return source, nil
+}
+
+// matchesSource ...
+func (b *Bookcision) matchesSource(source string) bool {
+	return b.SourceRegex == nil || b.SourceRegex.MatchString(source)
+}
+
+// bookcisionHighlights returns the highlights and the standalone notes from Bookcision. A note is
+// paired with the highlight from the same entry of the Bookcision export, which does not depend on
+// the order of the clippings. Note-only notes, and notes without a highlight in their entry, are
+// standalone notes.
+func (b *Bookcision) bookcisionHighlights(bookcision parser.Clippings) ([]bookcisionHighlight, parser.Clippings) {
+	var output []bookcisionHighlight
+	entries := make(map[int]int)
+	for _, clipping := range bookcision {
+		if clipping.Type != parser.ClippingType_Highlight {
+			continue
+		}
+
+		if clipping.BookcisionIndex != 0 {
+			entries[clipping.BookcisionIndex] = len(output)
+		}
+		output = append(output, bookcisionHighlight{
+			clipping: clipping,
+		})
+	}
+
+	var notes parser.Clippings
+	for i, clipping := range bookcision {
+		if clipping.Type != parser.ClippingType_Note {
+			continue
+		}
+
+		if j, ok := entries[clipping.BookcisionIndex]; ok && !clipping.NoteOnly && output[j].note == nil {
+			output[j].note = &bookcision[i]
+			continue
+		}
+
+		notes = append(notes, clipping)
+	}
+
+	return output, notes
+}
+
+// candidates returns the Bookcision highlights which are within the location tolerance of the given
+// Kindle highlight, ordered from the best match to the worst match. A highlight from a source which
+// is not the book of the Bookcision export does not have any candidates, because locations from
+// different books can not be compared.
+//
+// The score of a candidate is the distance between the start locations, plus a penalty if the
+// Bookcision highlight starts after the Kindle highlight ends, plus a penalty if only one of the two
+// highlights has a note attached to it. For highlights which are not placeholders, the text is
+// compared as well.
+func (b *Bookcision) candidates(kindle parser.Clipping, source string, kindleHasNote bool, highlights []bookcisionHighlight) []candidate {
+	if kindle.Source != source {
+		return nil
+	}
+
+	var output []candidate
+	for i, highlight := range highlights {
+		distance := highlight.clipping.LocationInSource.Start - kindle.LocationInSource.Start
+		if distance < 0 {
+			distance = -distance
+		}
+
+		if distance > b.LocationTolerance {
+			continue
+		}
+
+		score := distance
+		if highlight.clipping.LocationInSource.Start > endLocation(kindle) {
+			score++
+		}
+		if (highlight.note != nil) != kindleHasNote {
+			score++
+		}
+		if !isPlaceholder(kindle) {
+			score += textDistance(kindle.Text, highlight.clipping.Text)
+		}
+
+		output = append(output, candidate{
+			index: i,
+			score: score,
+		})
+	}
+
+	sort.SliceStable(output, func(i, j int) bool {
+		return output[i].score < output[j].score
+	})
+
+	return output
+}
+
+// textDistance is 0 for texts which are the same after normalization, 1 when one of the texts
+// contains the other (e.g. a truncated highlight) and 2 otherwise.
+func textDistance(a, b string) int {
+	a, b = utils.NormalizeText(a), utils.NormalizeText(b)
+	switch {
+	case a == b:
+		return 0
+	case strings.Contains(a, b), strings.Contains(b, a):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// bestScore ...
+func bestScore(candidates []candidate) int {
+	if len(candidates) == 0 {
+		return int(^uint(0) >> 1)
+	}
+
+	return candidates[0].score
+}
+
+// endLocation returns the end location of the clipping. Clippings which span a single location do
+// not have an end location.
+func endLocation(clipping parser.Clipping) int {
+	if clipping.LocationInSource.End < clipping.LocationInSource.Start {
+		return clipping.LocationInSource.Start
+	}
+
+	return clipping.LocationInSource.End
+}
+
+// hasNote returns true if any of the given note locations is inside the location range of the
+// highlight.
+func hasNote(highlight parser.Clipping, noteLocations []int) bool {
+	for _, location := range noteLocations {
+		if location >= highlight.LocationInSource.Start && location <= endLocation(highlight) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// attachedTo returns true if the note is attached to any of the given highlights from the same
+// source.
+func attachedTo(note parser.Clipping, highlights parser.Clippings) bool {
+	for _, highlight := range highlights {
+		if highlight.Source == note.Source && hasNote(highlight, []int{note.LocationInSource.Start}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isPlaceholder returns true for highlights whose text was not written to the clippings file
+// because the clipping limit for the book was reached.
+func isPlaceholder(clipping parser.Clipping) bool {
+	return clipping.Type == parser.ClippingType_Highlight && strings.Contains(clipping.Text, parser.KindleClippingLimitMessage)
 }
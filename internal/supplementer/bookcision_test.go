@@ -0,0 +1,231 @@
+package supplementer
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"go.uber.org/zap"
+)
+
+func TestBookcisionHighlightsPairsNotesByEntry(t *testing.T) {
+	// Two highlights start at the same location, and all clippings from Bookcision have the same
+	// (zero) create time, so sorting can put both notes after both highlights.
+	bookcision := parser.Clippings{
+		{Source: "Book", Type: parser.ClippingType_Highlight, LocationInSource: parser.Location{Start: 10}, Text: "first highlight", BookcisionIndex: 1},
+		{Source: "Book", Type: parser.ClippingType_Highlight, LocationInSource: parser.Location{Start: 10}, Text: "second highlight", BookcisionIndex: 2},
+		{Source: "Book", Type: parser.ClippingType_Note, LocationInSource: parser.Location{Start: 10}, Text: "first note", BookcisionIndex: 1},
+		{Source: "Book", Type: parser.ClippingType_Note, LocationInSource: parser.Location{Start: 10}, Text: "second note", BookcisionIndex: 2},
+		{Source: "Book", Type: parser.ClippingType_Note, LocationInSource: parser.Location{Start: 10}, Text: "note only", NoteOnly: true, BookcisionIndex: 3},
+	}
+	sort.Sort(bookcision)
+
+	b := &Bookcision{Logger: zap.NewNop()}
+	highlights, standaloneNotes := b.bookcisionHighlights(bookcision)
+
+	want := map[string]string{
+		"first highlight":  "first note",
+		"second highlight": "second note",
+	}
+	if len(highlights) != len(want) {
+		t.Fatalf("got %d highlights, want %d", len(highlights), len(want))
+	}
+	for _, highlight := range highlights {
+		if highlight.note == nil {
+			t.Errorf("highlight %q does not have a note", highlight.clipping.Text)
+			continue
+		}
+		if highlight.note.Text != want[highlight.clipping.Text] {
+			t.Errorf("highlight %q has the note %q, want %q", highlight.clipping.Text, highlight.note.Text, want[highlight.clipping.Text])
+		}
+	}
+
+	if len(standaloneNotes) != 1 || standaloneNotes[0].Text != "note only" {
+		t.Errorf("standalone notes are %v, want only the note-only note", standaloneNotes)
+	}
+}
+
+func TestMissingIsReproducible(t *testing.T) {
+	created := time.Date(2023, 4, 16, 10, 13, 54, 0, time.UTC)
+	kindleInput := parser.Clippings{
+		{Source: "Book", Type: parser.ClippingType_Highlight, LocationInSource: parser.Location{Start: 10, End: 12}, CreateTime: created.Add(time.Minute), Text: "second highlight"},
+		{Source: "Book", Type: parser.ClippingType_Highlight, LocationInSource: parser.Location{Start: 10, End: 12}, CreateTime: created, Text: "first highlight"},
+	}
+
+	// Both notes are added at the end location of their highlight, with the same create time, so
+	// only their order in the output tells them apart.
+	highlights := []bookcisionHighlight{
+		{
+			clipping: parser.Clipping{Source: "Book", Type: parser.ClippingType_Highlight, LocationInSource: parser.Location{Start: 10}, Text: "second highlight"},
+			note:     &parser.Clipping{Source: "Book", Type: parser.ClippingType_Note, LocationInSource: parser.Location{Start: 10}, Text: "second note"},
+		},
+		{
+			clipping: parser.Clipping{Source: "Book", Type: parser.ClippingType_Highlight, LocationInSource: parser.Location{Start: 10}, Text: "first highlight"},
+			note:     &parser.Clipping{Source: "Book", Type: parser.ClippingType_Note, LocationInSource: parser.Location{Start: 10}, Text: "first note"},
+		},
+	}
+	matches := map[int]int{0: 0, 1: 1}
+
+	b := &Bookcision{Logger: zap.NewNop()}
+	for run := 0; run < 50; run++ {
+		output := b.missing(kindleInput, "Book", map[string][]int{}, highlights, nil, matches)
+		if len(output) != 2 || output[0].Text != "second note" || output[1].Text != "first note" {
+			t.Fatalf("run %d: added notes are %v, want the notes in the order of the Kindle input", run, output)
+		}
+	}
+}
+
+func TestMergeWithReportFillsPlaceholders(t *testing.T) {
+	placeholder := func(start, end int) parser.Clipping {
+		return parser.Clipping{Source: "Book (Author)", Type: parser.ClippingType_Highlight, LocationInSource: parser.Location{Start: start, End: end}, Text: parser.KindleClippingLimitMessage}
+	}
+	highlight := func(start int, text string, index int) parser.Clipping {
+		return parser.Clipping{Source: "Book", Authors: "Author", Type: parser.ClippingType_Highlight, LocationInSource: parser.Location{Start: start}, Text: text, BookcisionIndex: index}
+	}
+	note := func(start int, index int) parser.Clipping {
+		return parser.Clipping{Source: "Book", Authors: "Author", Type: parser.ClippingType_Note, LocationInSource: parser.Location{Start: start}, Text: "a note", BookcisionIndex: index}
+	}
+
+	tests := []struct {
+		name       string
+		kindle     parser.Clippings
+		bookcision parser.Clippings
+		filled     string
+		unfilled   int
+		ambiguous  int
+	}{
+		{
+			name:       "at the tolerance",
+			kindle:     parser.Clippings{placeholder(10, 14)},
+			bookcision: parser.Clippings{highlight(12, "two locations away", 1)},
+			filled:     "two locations away",
+		},
+		{
+			name:       "beyond the tolerance",
+			kindle:     parser.Clippings{placeholder(10, 14)},
+			bookcision: parser.Clippings{highlight(13, "three locations away", 1)},
+			unfilled:   1,
+		},
+		{
+			name:       "tie between candidates on both sides",
+			kindle:     parser.Clippings{placeholder(10, 14)},
+			bookcision: parser.Clippings{highlight(9, "before", 1), highlight(11, "after", 2)},
+			ambiguous:  1,
+		},
+		{
+			// The placeholder ends where it starts, so the candidate which starts after it has a
+			// penalty.
+			name:       "tie broken by the end location",
+			kindle:     parser.Clippings{placeholder(10, 0)},
+			bookcision: parser.Clippings{highlight(9, "before", 1), highlight(11, "after", 2)},
+			filled:     "before",
+		},
+		{
+			name: "tie broken by the note",
+			kindle: parser.Clippings{
+				placeholder(10, 14),
+				{Source: "Book (Author)", Type: parser.ClippingType_Note, LocationInSource: parser.Location{Start: 14}, Text: "a note"},
+			},
+			bookcision: parser.Clippings{highlight(9, "before", 1), highlight(11, "after", 2), note(11, 2)},
+			filled:     "after",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := &Bookcision{LocationTolerance: 2, Logger: zap.NewNop()}
+			_, report, err := b.MergeWithReport(test.kindle, test.bookcision)
+			if err != nil {
+				t.Fatalf("could not merge: %v", err)
+			}
+
+			var filled string
+			if len(report.Filled) > 0 {
+				filled = report.Filled[0].Text
+			}
+			if len(report.Filled) > 1 || filled != test.filled {
+				t.Errorf("filled placeholders are %v, want one with the text %q", report.Filled, test.filled)
+			}
+			if len(report.Unfilled) != test.unfilled {
+				t.Errorf("got %d unfilled placeholders, want %d", len(report.Unfilled), test.unfilled)
+			}
+			if len(report.Ambiguous) != test.ambiguous {
+				t.Errorf("got %d ambiguous placeholders, want %d", len(report.Ambiguous), test.ambiguous)
+			}
+		})
+	}
+}
+
+func TestMergeWithReportPrunesDeleted(t *testing.T) {
+	kindle := parser.Clippings{
+		{Source: "Book (Author)", Type: parser.ClippingType_Highlight, LocationInSource: parser.Location{Start: 10, End: 12}, Text: "still on the device"},
+		{Source: "Book (Author)", Type: parser.ClippingType_Highlight, LocationInSource: parser.Location{Start: 50, End: 52}, Text: "deleted on the device"},
+		{Source: "Book (Author)", Type: parser.ClippingType_Note, LocationInSource: parser.Location{Start: 52}, Text: "note on the deleted highlight"},
+	}
+
+	tests := []struct {
+		name         string
+		sourceRegex  *regexp.Regexp
+		pruneDeleted bool
+		title        string
+		authors      string
+		wantErr      bool
+		wantOutput   int
+	}{
+		{
+			name:         "export covers the source",
+			pruneDeleted: true,
+			title:        "Book",
+			authors:      "Author",
+			wantOutput:   1,
+		},
+		{
+			// The source filter chose the only source, but the export is another book, so nothing may
+			// be removed.
+			name:         "export does not cover the source",
+			sourceRegex:  regexp.MustCompile(`^Book`),
+			pruneDeleted: true,
+			title:        "War and Peace",
+			authors:      "Someone Else",
+			wantErr:      true,
+		},
+		{
+			name:        "deleted highlights are only reported without pruning",
+			sourceRegex: regexp.MustCompile(`^Book`),
+			title:       "War and Peace",
+			authors:     "Someone Else",
+			wantOutput:  3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := make(parser.Clippings, len(kindle))
+			copy(input, kindle)
+			bookcision := parser.Clippings{
+				{Source: test.title, Authors: test.authors, Type: parser.ClippingType_Highlight, LocationInSource: parser.Location{Start: 10}, Text: "still on the device", BookcisionIndex: 1},
+			}
+
+			b := &Bookcision{SourceRegex: test.sourceRegex, LocationTolerance: 2, PruneDeleted: test.pruneDeleted, Logger: zap.NewNop()}
+			output, report, err := b.MergeWithReport(input, bookcision)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("merged without an error, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("could not merge: %v", err)
+			}
+
+			if len(report.Deleted) != 2 {
+				t.Errorf("got %d deleted clippings, want the deleted highlight and its note", len(report.Deleted))
+			}
+			if len(output) != test.wantOutput {
+				t.Errorf("got %d clippings in the output, want %d", len(output), test.wantOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package supplementer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+)
+
+// MatchSource finds the Kindle source which is the same book as the given title and authors from a
+// Bookcision export.
+//
+// The title must be the same as the title in the Kindle source after normalization, or one of them
+// must contain the other (e.g. when one of them has a subtitle). Kindle writes authors as "Last,
+// First" for some books and "First Last" for others, so the authors are compared as sets of words.
+// An error is returned if no source matches, or if more than one source matches equally well.
+func MatchSource(sources []string, title, authors string) (string, error) {
+	wantTitle := utils.NormalizeText(title)
+	wantAuthors := utils.Shingles(authors, 1)
+
+	type match struct {
+		source string
+		score  float64
+	}
+
+	var matches []match
+	for _, source := range sources {
+		sourceTitle, sourceAuthors := utils.SplitSource(source)
+		gotTitle := utils.NormalizeText(sourceTitle)
+
+		var score float64
+		switch {
+		case gotTitle == "" || wantTitle == "":
+			continue
+		case gotTitle == wantTitle:
+			score = 2
+		case strings.Contains(gotTitle, wantTitle), strings.Contains(wantTitle, gotTitle):
+			score = 1
+		default:
+			continue
+		}
+
+		// Authors break ties between books with the same title, but they can not make a book with
+		// a different title match.
+		score += utils.Jaccard(wantAuthors, utils.Shingles(sourceAuthors, 1))
+
+		matches = append(matches, match{
+			source: source,
+			score:  score,
+		})
+	}
+
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no source matches the title '%s' by '%s'", title, authors)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	if len(matches) > 1 && matches[0].score == matches[1].score {
+		return "", fmt.Errorf("more than one source matches the title '%s' by '%s': '%s' and '%s'", title, authors, matches[0].source, matches[1].source)
+	}
+
+	return matches[0].source, nil
+}
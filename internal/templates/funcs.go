@@ -0,0 +1,152 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/utils"
+)
+
+// Locale_English and Locale_Japanese are the locales which are supported by the date functions.
+// These are the languages in which Kindle writes its clippings file.
+const (
+	Locale_English  = "en"
+	Locale_Japanese = "ja"
+)
+
+var japaneseWeekdays = []string{"日", "月", "火", "水", "木", "金", "土"}
+
+// FuncMap returns the functions which are available to every template of the commands in this
+// project. The map can be used with both text/template and html/template, but ParseHTML leaves out
+// escapeHTML, because html/template escapes the output itself.
+//
+// Dates:
+//
+//   - date LAYOUT TIME: the time in a Golang layout, e.g. {{ date "2006-01-02" .CreateTime }}
+//   - localDate LOCALE TIME: the date as it is written in the locale, e.g. "January 2, 2006" or
+//     "2006年1月2日"
+//   - localDateTime LOCALE TIME: the date with the weekday and the time of day
+//   - localMonth LOCALE TIME: the month and the year, e.g. "January 2006" or "2006年1月"
+//
+// Text:
+//
+//   - wrap WIDTH TEXT: the text broken into lines which are at most WIDTH runes long
+//   - indent SPACES TEXT: every line of the text indented by SPACES spaces
+//   - prefix PREFIX TEXT: every line of the text prefixed with PREFIX, e.g. "> " for a blockquote
+//     in Markdown
+//   - truncate LENGTH TEXT: the text on a single line, cut to at most LENGTH runes
+//   - escapeOrg, escapeMarkdown, escapeHTML, escapeLaTeX TEXT: the text escaped for the format;
+//     escapeHTML is only available to templates which are parsed with Parse
+//   - orgTags TAGS: a list of tags in the format of Org mode, e.g. ":quote:cn:"
+//   - stripTag, tagOf, roleOf TEXT: see markup.Markup.FuncMap
+//
+// Sources and pages:
+//
+//   - title SOURCE, authors SOURCE: the title and the authors of the book, from a source in the
+//     Kindle format "Title (Authors)"
+//   - isRoman PAGE: true if the page is a Roman numeral, e.g. in the front matter of a book
+//   - fromRoman PAGE: the number of a page which is a Roman numeral, or 0
+//   - toRoman NUMBER: the lowercase Roman numeral of the number
+//
+// Grouping, each of which returns a list of Group with .Key and .Clippings:
+//
+//   - groupBySource CLIPPINGS
+//   - groupByMonth CLIPPINGS: the key is the month in the format "2006-01"
+//   - groupByChapter CLIPPINGS: the key is the name of the chapter, for the clippings of a book
+func FuncMap(vocabulary markup.Markup) template.FuncMap {
+	funcs := template.FuncMap{
+		"date": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+		"localDate":     localDate,
+		"localDateTime": localDateTime,
+		"localMonth":    localMonth,
+
+		"wrap": func(width int, text string) (string, error) {
+			wrapped, err := utils.Wrap(text, width)
+			return strings.TrimSuffix(wrapped, "\n"), err
+		},
+		"indent": func(spaces int, text string) string {
+			prefix := strings.Repeat(" ", spaces)
+			return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+		},
+		"prefix": func(prefix string, text string) string {
+			return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+		},
+		"truncate": func(length int, text string) string {
+			return utils.Truncate(text, length)
+		},
+		"escapeOrg":      utils.EscapeOrg,
+		"escapeMarkdown": utils.EscapeMarkdown,
+		"escapeHTML":     utils.EscapeHTML,
+		"escapeLaTeX":    utils.EscapeLaTeX,
+		"orgTags":        utils.OrgTags,
+
+		"title": func(source string) string {
+			title, _ := utils.SplitSource(source)
+			return title
+		},
+		"authors": func(source string) string {
+			_, authors := utils.SplitSource(source)
+			return authors
+		},
+		"isRoman": func(page string) bool {
+			_, ok := utils.FromRoman(page)
+			return ok
+		},
+		"fromRoman": func(page string) int {
+			number, _ := utils.FromRoman(page)
+			return number
+		},
+		"toRoman": utils.ToRoman,
+
+		"groupBySource": GroupBySource,
+		"groupByMonth":  GroupByMonth,
+		"groupByChapter": func(clippings parser.Clippings) []Group {
+			return GroupByChapter(clippings, vocabulary)
+		},
+	}
+
+	for name, f := range vocabulary.FuncMap() {
+		funcs[name] = f
+	}
+
+	return funcs
+}
+
+func localDate(locale string, t time.Time) (string, error) {
+	switch locale {
+	case Locale_English:
+		return t.Format("January 2, 2006"), nil
+	case Locale_Japanese:
+		return t.Format("2006年1月2日"), nil
+	default:
+		return "", fmt.Errorf("unsupported locale '%s'", locale)
+	}
+}
+
+func localDateTime(locale string, t time.Time) (string, error) {
+	switch locale {
+	case Locale_English:
+		return t.Format("Monday, January 2, 2006 3:04 PM"), nil
+	case Locale_Japanese:
+		return t.Format("2006年1月2日") + japaneseWeekdays[t.Weekday()] + "曜日 " + t.Format("15:04"), nil
+	default:
+		return "", fmt.Errorf("unsupported locale '%s'", locale)
+	}
+}
+
+func localMonth(locale string, t time.Time) (string, error) {
+	switch locale {
+	case Locale_English:
+		return t.Format("January 2006"), nil
+	case Locale_Japanese:
+		return t.Format("2006年1月"), nil
+	default:
+		return "", fmt.Errorf("unsupported locale '%s'", locale)
+	}
+}
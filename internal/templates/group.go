@@ -0,0 +1,117 @@
+package templates
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/icyflame/kindle-my-clippings-parser/internal/markup"
+	"github.com/icyflame/kindle-my-clippings-parser/internal/parser"
+)
+
+// Group is a set of clippings with the same key, e.g. the same source or the same month.
+type Group struct {
+	Key       string
+	Clippings parser.Clippings
+}
+
+// GroupBySource groups the clippings by source. Groups are sorted by source, and the clippings in
+// each group are sorted by location.
+func GroupBySource(input parser.Clippings) []Group {
+	return group(sorted(input), func(c parser.Clipping) string {
+		return c.Source
+	})
+}
+
+// GroupByMonth groups the clippings by the month in which they were created, e.g. "2023-09". Groups
+// are sorted by month, and the clippings in each group are sorted by create time.
+func GroupByMonth(input parser.Clippings) []Group {
+	clippings := make(parser.Clippings, len(input))
+	copy(clippings, input)
+	sort.SliceStable(clippings, func(i, j int) bool {
+		return clippings[i].CreateTime.Before(clippings[j].CreateTime)
+	})
+
+	return group(clippings, func(c parser.Clipping) string {
+		return c.CreateTime.Format("2006-01")
+	})
+}
+
+// GroupByChapter groups the clippings of a book by the chapter in which they appear. Chapters are
+// marked with chapter name notes, in the same way as for cmd/summary-builder. The key of each group
+// is the name of the chapter; clippings before the first chapter are in a group with an empty key.
+// The chapter name notes, and the highlights which are the names of chapters, are not in any group.
+func GroupByChapter(input parser.Clippings, vocabulary markup.Markup) []Group {
+	clippings := sorted(input)
+
+	type chapter struct {
+		source string
+		start  int
+		name   string
+	}
+
+	var chapters []chapter
+	skip := make(map[int]bool)
+	for i, clipping := range clippings {
+		_, name, ok := vocabulary.ChapterName(clipping.Text)
+		if !ok || clipping.Type != parser.ClippingType_Note {
+			continue
+		}
+		skip[i] = true
+
+		// The chapter starts at the highlight which is its name, or at the note if the note is not
+		// attached to a highlight.
+		thisChapter := chapter{
+			source: clipping.Source,
+			start:  clipping.LocationInSource.Start,
+			name:   name,
+		}
+		if j, ok := clippings.HighlightForNote(i); ok {
+			skip[j] = true
+			thisChapter.start = clippings[j].LocationInSource.Start
+			thisChapter.name = clippings[j].Text
+		}
+		if thisChapter.name == "" {
+			thisChapter.name = fmt.Sprintf("Chapter at location %d", clipping.LocationInSource.Start)
+		}
+
+		chapters = append(chapters, thisChapter)
+	}
+
+	var kept parser.Clippings
+	for i, clipping := range clippings {
+		if !skip[i] {
+			kept = append(kept, clipping)
+		}
+	}
+
+	return group(kept, func(c parser.Clipping) string {
+		name := ""
+		for _, chapter := range chapters {
+			if chapter.source == c.Source && chapter.start <= c.LocationInSource.Start {
+				name = chapter.name
+			}
+		}
+		return name
+	})
+}
+
+// group splits the clippings into groups of consecutive clippings with the same key.
+func group(clippings parser.Clippings, key func(parser.Clipping) string) []Group {
+	var output []Group
+	for _, clipping := range clippings {
+		k := key(clipping)
+		if len(output) == 0 || output[len(output)-1].Key != k {
+			output = append(output, Group{Key: k})
+		}
+		output[len(output)-1].Clippings = append(output[len(output)-1].Clippings, clipping)
+	}
+
+	return output
+}
+
+func sorted(input parser.Clippings) parser.Clippings {
+	clippings := make(parser.Clippings, len(input))
+	copy(clippings, input)
+	sort.Sort(clippings)
+	return clippings
+}
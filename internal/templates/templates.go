@@ -0,0 +1,108 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// Overrides are the template files which replace the default templates of a command.
+type Overrides struct {
+	// TemplatePath is a file which replaces the main template, i.e. the template which is executed.
+	TemplatePath string
+
+	// TemplateDir is a directory with files which replace the default templates of the same name.
+	// Default templates which are not in this directory are still used, so a directory can override
+	// only some of them.
+	TemplateDir string
+}
+
+// Parse parses the named default templates from the file system, after replacing them with the
+// overrides. The first name is the main template, which is returned. The other templates can be
+// used by the main template with the template action, e.g. {{ template "chapter.org.tmpl" . }}.
+func Parse(defaults fs.FS, overrides Overrides, funcs template.FuncMap, names ...string) (*template.Template, error) {
+	sources, err := read(defaults, overrides, names)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := template.New(names[0]).Funcs(funcs)
+	if _, err := tmpl.Parse(sources[0]); err != nil {
+		return nil, fmt.Errorf("could not parse template '%s' > %w", names[0], err)
+	}
+
+	for i, name := range names[1:] {
+		if _, err := tmpl.New(name).Parse(sources[i+1]); err != nil {
+			return nil, fmt.Errorf("could not parse template '%s' > %w", name, err)
+		}
+	}
+
+	return tmpl, nil
+}
+
+// ParseHTML is the same as Parse, for templates which output HTML. The text of clippings is escaped
+// when these templates are executed, so escapeHTML is removed from the functions: the text which it
+// returns would be escaped a second time.
+func ParseHTML(defaults fs.FS, overrides Overrides, funcs htmltemplate.FuncMap, names ...string) (*htmltemplate.Template, error) {
+	sources, err := read(defaults, overrides, names)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlFuncs := make(htmltemplate.FuncMap, len(funcs))
+	for name, f := range funcs {
+		if name != "escapeHTML" {
+			htmlFuncs[name] = f
+		}
+	}
+
+	tmpl := htmltemplate.New(names[0]).Funcs(htmlFuncs)
+	if _, err := tmpl.Parse(sources[0]); err != nil {
+		return nil, fmt.Errorf("could not parse template '%s' > %w", names[0], err)
+	}
+
+	for i, name := range names[1:] {
+		if _, err := tmpl.New(name).Parse(sources[i+1]); err != nil {
+			return nil, fmt.Errorf("could not parse template '%s' > %w", name, err)
+		}
+	}
+
+	return tmpl, nil
+}
+
+// read returns the contents of the named templates. The main template is read from the template
+// path, if it is set. Every other template is read from the template directory, if it has a file
+// of the same name, and from the default templates otherwise.
+func read(defaults fs.FS, overrides Overrides, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, errors.New("at least one template name is required")
+	}
+
+	sources := make([]string, len(names))
+	for i, name := range names {
+		var contents []byte
+		var err error
+		switch {
+		case i == 0 && overrides.TemplatePath != "":
+			contents, err = os.ReadFile(overrides.TemplatePath)
+		case overrides.TemplateDir != "":
+			contents, err = os.ReadFile(filepath.Join(overrides.TemplateDir, name))
+			if errors.Is(err, fs.ErrNotExist) {
+				contents, err = fs.ReadFile(defaults, name)
+			}
+		default:
+			contents, err = fs.ReadFile(defaults, name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read template '%s' > %w", name, err)
+		}
+
+		sources[i] = string(contents)
+	}
+
+	return sources, nil
+}
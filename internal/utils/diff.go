@@ -0,0 +1,76 @@
+package utils
+
+import "strings"
+
+// DiffType is the type of a single operation in a diff.
+type DiffType string
+
+const (
+	DiffType_Equal  DiffType = "equal"
+	DiffType_Delete DiffType = "delete"
+	DiffType_Insert DiffType = "insert"
+)
+
+// DiffOp is a run of words which are the same in both texts, only in the first text (delete), or
+// only in the second text (insert).
+type DiffOp struct {
+	Type DiffType `json:"type" yaml:"type"`
+	Text string   `json:"text" yaml:"text"`
+}
+
+// DiffWords returns the operations which turn text a into text b, comparing the texts word by
+// word. Consecutive words with the same operation are joined into a single operation.
+func DiffWords(a, b string) []DiffOp {
+	wordsA, wordsB := strings.Fields(a), strings.Fields(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of wordsA[i:] and wordsB[j:].
+	lcs := make([][]int, len(wordsA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(wordsB)+1)
+	}
+	for i := len(wordsA) - 1; i >= 0; i-- {
+		for j := len(wordsB) - 1; j >= 0; j-- {
+			switch {
+			case wordsA[i] == wordsB[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var output []DiffOp
+	add := func(diffType DiffType, word string) {
+		if len(output) > 0 && output[len(output)-1].Type == diffType {
+			output[len(output)-1].Text += " " + word
+			return
+		}
+		output = append(output, DiffOp{Type: diffType, Text: word})
+	}
+
+	i, j := 0, 0
+	for i < len(wordsA) && j < len(wordsB) {
+		switch {
+		case wordsA[i] == wordsB[j]:
+			add(DiffType_Equal, wordsA[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(DiffType_Delete, wordsA[i])
+			i++
+		default:
+			add(DiffType_Insert, wordsB[j])
+			j++
+		}
+	}
+	for ; i < len(wordsA); i++ {
+		add(DiffType_Delete, wordsA[i])
+	}
+	for ; j < len(wordsB); j++ {
+		add(DiffType_Insert, wordsB[j])
+	}
+
+	return output
+}
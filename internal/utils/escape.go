@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// orgTagCharRx matches the characters which are not allowed in Org tags. Org tags can only have
+// letters, numbers and the characters _@#%. Only ASCII letters and numbers are kept, which are the
+// tags that the export to org-roam reads back from existing headings.
+var orgTagCharRx = regexp.MustCompile(`[^\w@#%]`)
+
+var markdownReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`<`, `\<`,
+	`>`, `\>`,
+	`#`, `\#`,
+	`|`, `\|`,
+)
+
+var laTeXReplacer = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+)
+
+// EscapeOrg escapes the lines of the text which Org mode would read as a heading, a keyword or a
+// comment, by adding a zero width space at the start of the line. The Org mode manual escapes these
+// lines with a comma, but Org only removes the comma inside source and example blocks, and the text
+// of clippings is also written in quote blocks and in plain paragraphs, where the comma would be
+// shown. A zero width space is not shown anywhere.
+func EscapeOrg(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "*") || strings.HasPrefix(line, "#") {
+			lines[i] = "\u200b" + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// OrgTags returns the tags in the format of Org mode, e.g. ":quote:cn:" for "#quote" and "#cn", or an
+// empty string if there are no tags. The # at the start of a tag is removed, characters which are
+// not allowed in Org tags are replaced with _, and duplicate tags are dropped.
+func OrgTags(tags []string) string {
+	var kept []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = orgTagCharRx.ReplaceAllString(strings.TrimPrefix(tag, "#"), "_")
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			kept = append(kept, tag)
+		}
+	}
+
+	if len(kept) == 0 {
+		return ""
+	}
+
+	return ":" + strings.Join(kept, ":") + ":"
+}
+
+// EscapeMarkdown escapes the characters which start emphasis, code, links, headings, HTML and
+// tables in Markdown.
+func EscapeMarkdown(text string) string {
+	return markdownReplacer.Replace(text)
+}
+
+// EscapeHTML escapes the characters which have a special meaning in HTML.
+func EscapeHTML(text string) string {
+	return html.EscapeString(text)
+}
+
+// EscapeLaTeX escapes the characters which have a special meaning in LaTeX and BibTeX.
+func EscapeLaTeX(text string) string {
+	return laTeXReplacer.Replace(text)
+}
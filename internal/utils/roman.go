@@ -0,0 +1,51 @@
+package utils
+
+import "strings"
+
+var romanNumerals = []struct {
+	value   int
+	numeral string
+}{
+	{1000, "m"}, {900, "cm"}, {500, "d"}, {400, "cd"},
+	{100, "c"}, {90, "xc"}, {50, "l"}, {40, "xl"},
+	{10, "x"}, {9, "ix"}, {5, "v"}, {4, "iv"}, {1, "i"},
+}
+
+// ToRoman returns the lowercase Roman numeral of the number, which is how Kindle writes the pages of
+// the front matter of a book. Numbers which are not positive return an empty string.
+func ToRoman(number int) string {
+	var builder strings.Builder
+	for _, r := range romanNumerals {
+		for number >= r.value {
+			builder.WriteString(r.numeral)
+			number -= r.value
+		}
+	}
+
+	return builder.String()
+}
+
+// FromRoman returns the number of the Roman numeral, in upper or lower case, and true. It returns
+// false if the text is not a valid Roman numeral, e.g. for pages which are Arabic numerals.
+func FromRoman(numeral string) (int, bool) {
+	lower := strings.ToLower(strings.TrimSpace(numeral))
+	if lower == "" {
+		return 0, false
+	}
+
+	number, rest := 0, lower
+	for _, r := range romanNumerals {
+		for strings.HasPrefix(rest, r.numeral) {
+			number += r.value
+			rest = rest[len(r.numeral):]
+		}
+	}
+
+	// Invalid numerals like "iiii" or "ic" are either not consumed completely, or are not written
+	// the same way again.
+	if rest != "" || ToRoman(number) != lower {
+		return 0, false
+	}
+
+	return number, true
+}
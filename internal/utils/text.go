@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeText lowercases the given text, drops punctuation and collapses all whitespace into
+// single spaces. Two highlights of the same passage from different editions of a book tend to
+// differ only in punctuation and spacing, so they compare equal after normalization.
+func NormalizeText(text string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune(' ')
+		}
+	}
+
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+// Shingles returns the set of word n-grams of the given size in the normalized text. Texts which
+// are shorter than the shingle size are returned as a single shingle.
+func Shingles(text string, size int) map[string]struct{} {
+	words := strings.Fields(NormalizeText(text))
+	output := make(map[string]struct{})
+	if len(words) == 0 {
+		return output
+	}
+
+	if size <= 0 || len(words) <= size {
+		output[strings.Join(words, " ")] = struct{}{}
+		return output
+	}
+
+	for i := 0; i+size <= len(words); i++ {
+		output[strings.Join(words[i:i+size], " ")] = struct{}{}
+	}
+
+	return output
+}
+
+// Jaccard returns the Jaccard similarity of two sets of shingles. The value is between 0 (nothing
+// in common) and 1 (identical sets).
+func Jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	common := 0
+	for shingle := range a {
+		if _, ok := b[shingle]; ok {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// SplitSource splits the source of a clipping into the title and the authors of the book. Kindle
+// writes the source as "Title (Authors)". Sources which do not end with the authors in parentheses
+// are returned as the title with empty authors.
+func SplitSource(source string) (title, authors string) {
+	source = strings.TrimSpace(source)
+	if !strings.HasSuffix(source, ")") {
+		return source, ""
+	}
+
+	open := strings.LastIndex(source, " (")
+	if open == -1 {
+		return source, ""
+	}
+
+	return strings.TrimSpace(source[:open]), strings.TrimSpace(source[open+2 : len(source)-1])
+}
+
+// maxFilenameLength is the largest number of runes in a file name returned by SafeFilename. Most
+// file systems limit file names to 255 bytes, and a rune can be as long as 4 bytes in UTF-8.
+const maxFilenameLength = 60
+
+// SafeFilename turns the source of a clipping into a name which can be used as a file name on any
+// operating system. Letters and digits in any script are kept, and everything else is replaced
+// with a hyphen. The extension is not added.
+func SafeFilename(source string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToLower(source) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune('-')
+		}
+	}
+
+	words := strings.FieldsFunc(builder.String(), func(r rune) bool {
+		return r == '-'
+	})
+
+	filename := strings.Join(words, "-")
+	if runes := []rune(filename); len(runes) > maxFilenameLength {
+		filename = strings.TrimRight(string(runes[:maxFilenameLength]), "-")
+	}
+
+	if filename == "" {
+		return "untitled"
+	}
+
+	return filename
+}
+
+// Truncate joins the lines of the text with spaces, and cuts the text to at most length runes,
+// followed by an ellipsis. It is used for the headings of clippings, which are a single line.
+func Truncate(text string, length int) string {
+	line := strings.Join(strings.Fields(text), " ")
+	if runes := []rune(line); len(runes) > length {
+		return strings.TrimSpace(string(runes[:length])) + "…"
+	}
+
+	return line
+}
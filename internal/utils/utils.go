@@ -15,17 +15,18 @@ const TextWidth = 100
 
 // MakePlaintextEmailFromClipping ...
 func MakePlaintextEmailFromClipping(c parser.Clipping) (string, error) {
-	var buffer bytes.Buffer
-	w := textutil.NewUTF8WrapWriter(&buffer, TextWidth)
-	if _, err := w.Write([]byte(c.Text)); err != nil {
+	wrapped, err := Wrap(c.Text, TextWidth)
+	if err != nil {
 		return "", fmt.Errorf("could not make plaintext email > %w", err)
 	}
 
-	if err := w.Flush(); err != nil {
-		return "", fmt.Errorf("could not flush all input to output buffer > %w", err)
-	}
+	clippingFormatted := strings.ReplaceAll("    "+wrapped, "\n", "\n    ")
 
-	clippingFormatted := strings.ReplaceAll("    "+buffer.String(), "\n", "\n    ")
+	// Only highlights which were supplemented from Bookcision have a link to the book.
+	var link string
+	if c.URL != "" {
+		link = fmt.Sprintf("Open in Kindle: %s\n\n", c.URL)
+	}
 
 	return fmt.Sprintf(`Today's excerpt is a highlight created on %s.
 
@@ -33,11 +34,27 @@ func MakePlaintextEmailFromClipping(c parser.Clipping) (string, error) {
 
 -- %s
 
-`,
-		c.CreateTime.Format("2006-01-02"), clippingFormatted, c.Source,
+%s`,
+		c.CreateTime.Format("2006-01-02"), clippingFormatted, c.Source, link,
 	), nil
 }
 
+// Wrap breaks the text into lines which are at most width runes long. Words which are longer than
+// the width are not broken.
+func Wrap(text string, width int) (string, error) {
+	var buffer bytes.Buffer
+	w := textutil.NewUTF8WrapWriter(&buffer, width)
+	if _, err := w.Write([]byte(text)); err != nil {
+		return "", fmt.Errorf("could not wrap text > %w", err)
+	}
+
+	if err := w.Flush(); err != nil {
+		return "", fmt.Errorf("could not flush all input to output buffer > %w", err)
+	}
+
+	return buffer.String(), nil
+}
+
 // FilterBySource ...
 func FilterBySource(input parser.Clippings, source string) parser.Clippings {
 	output := make(parser.Clippings, 0)